@@ -0,0 +1,255 @@
+// Package alert notifies webhooks of problems found by the service, repeated
+// alerts are deduplicated within a cooldown.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/distribute-check/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	SEVERITY_INFO     = "info"
+	SEVERITY_WARNING  = "warning"
+	SEVERITY_CRITICAL = "critical"
+
+	DEFAULT_COOLDOWN   = 15 * time.Minute
+	DEFAULT_QUEUE_SIZE = 256
+	SEND_TIMEOUT       = 10 * time.Second
+)
+
+var severities = map[string]int{
+	SEVERITY_INFO:     0,
+	SEVERITY_WARNING:  1,
+	SEVERITY_CRITICAL: 2,
+}
+
+var (
+	alertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_alerts_total",
+		Help: "Alerts by severity and whether they were queued, suppressed or dropped.",
+	}, []string{"severity", "result"})
+	sinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_alert_sink_errors_total",
+		Help: "Alerts a sink failed to deliver.",
+	}, []string{"sink"})
+)
+
+var logger = log.WithComponent(log.COMPONENT_ALERT)
+
+// ParseSeverity checks a severity name
+func ParseSeverity(severity string) (string, error) {
+	if _, ok := severities[severity]; !ok {
+		return "", fmt.Errorf("invalid alert severity %s", severity)
+	}
+	return severity, nil
+}
+
+// Alert describes a problem. Alerts of the same key are deduplicated.
+type Alert struct {
+	Key        string            `json:"key"`
+	Severity   string            `json:"severity"`
+	Title      string            `json:"title"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Time       time.Time         `json:"time"`
+	Resolved   bool              `json:"resolved"`
+	Suppressed int               `json:"suppressed"` // repeats within the cooldown since the last sent
+}
+
+// Sink delivers alerts, e.g. to a webhook.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// Config of the manager, zero values take the defaults.
+type Config struct {
+	Cooldown    time.Duration // an alert of a sent key is suppressed within it unless more severe
+	MinSeverity string        // less severe alerts are dropped
+	QueueSize   int
+}
+
+type active struct {
+	severity   string
+	sentAt     time.Time
+	suppressed int
+}
+
+// Manager deduplicates alerts and sends them to every sink in the
+// background while Run runs.
+type Manager struct {
+	config Config
+	sinks  []Sink
+	queue  chan *Alert
+	closed chan struct{}
+	close  sync.Once
+	lock   sync.Mutex
+	active map[string]*active
+}
+
+func NewManager(config Config, sinks ...Sink) *Manager {
+	if config.Cooldown <= 0 {
+		config.Cooldown = DEFAULT_COOLDOWN
+	}
+	if config.MinSeverity == "" {
+		config.MinSeverity = SEVERITY_INFO
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	return &Manager{
+		config: config,
+		sinks:  sinks,
+		queue:  make(chan *Alert, config.QueueSize),
+		closed: make(chan struct{}),
+		active: make(map[string]*active),
+	}
+}
+
+// Fire queues an alert unless it repeats an alert of its key sent within the
+// cooldown at the same or a higher severity. It never blocks, a nil manager
+// ignores it.
+func (m *Manager) Fire(alert *Alert) {
+	if m.admit(alert) {
+		m.enqueue(alert)
+	}
+}
+
+// FireWait is Fire waiting for room in the queue until ctx is done or the
+// manager is stopped rather than dropping the alert.
+func (m *Manager) FireWait(ctx context.Context, alert *Alert) {
+	if m.admit(alert) {
+		m.enqueueWait(ctx, alert)
+	}
+}
+
+// admit returns whether an alert is to be sent, recording it as sent
+func (m *Manager) admit(alert *Alert) bool {
+	if m == nil || severities[alert.Severity] < severities[m.config.MinSeverity] {
+		return false
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	m.lock.Lock()
+	a, ok := m.active[alert.Key]
+	if ok && alert.Time.Sub(a.sentAt) < m.config.Cooldown && severities[alert.Severity] <= severities[a.severity] {
+		a.suppressed++
+		m.lock.Unlock()
+		alertsFired.WithLabelValues(alert.Severity, "suppressed").Inc()
+		return false
+	}
+	if ok {
+		alert.Suppressed = a.suppressed
+	}
+	m.active[alert.Key] = &active{severity: alert.Severity, sentAt: alert.Time}
+	m.lock.Unlock()
+	return true
+}
+
+// Resolve sends a resolved alert if an alert of key was sent
+func (m *Manager) Resolve(key, title, message string) {
+	if alert := m.resolved(key, title, message); alert != nil {
+		m.enqueue(alert)
+	}
+}
+
+// ResolveWait is Resolve waiting for room in the queue like FireWait
+func (m *Manager) ResolveWait(ctx context.Context, key, title, message string) {
+	if alert := m.resolved(key, title, message); alert != nil {
+		m.enqueueWait(ctx, alert)
+	}
+}
+
+// resolved returns the resolved alert of key, nil if none was sent
+func (m *Manager) resolved(key, title, message string) *Alert {
+	if m == nil {
+		return nil
+	}
+	m.lock.Lock()
+	a, ok := m.active[key]
+	delete(m.active, key)
+	m.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	return &Alert{
+		Key:        key,
+		Severity:   SEVERITY_INFO,
+		Title:      title,
+		Message:    message,
+		Time:       time.Now(),
+		Resolved:   true,
+		Suppressed: a.suppressed,
+	}
+}
+
+func (m *Manager) enqueue(alert *Alert) {
+	select {
+	case m.queue <- alert:
+		alertsFired.WithLabelValues(alert.Severity, "queued").Inc()
+	default:
+		alertsFired.WithLabelValues(alert.Severity, "dropped").Inc()
+		logger.With("key", alert.Key, "severity", alert.Severity).Warn("alert queue full, dropped alert")
+	}
+}
+
+func (m *Manager) enqueueWait(ctx context.Context, alert *Alert) {
+	select {
+	case m.queue <- alert:
+		alertsFired.WithLabelValues(alert.Severity, "queued").Inc()
+	case <-m.closed:
+		alertsFired.WithLabelValues(alert.Severity, "dropped").Inc()
+		logger.With("key", alert.Key, "severity", alert.Severity).Warn("alert manager stopped, dropped alert")
+	case <-ctx.Done():
+		alertsFired.WithLabelValues(alert.Severity, "dropped").Inc()
+		logger.With("key", alert.Key, "severity", alert.Severity).Warn("alert not queued in time, dropped alert")
+	}
+}
+
+// Run sends the queued alerts until Stop, then the alerts already queued. It
+// outlives ctx so alerts fired by components still draining are sent, the
+// manager is to be stopped after them.
+func (m *Manager) Run(ctx context.Context) error {
+	for {
+		select {
+		case alert := <-m.queue:
+			m.send(alert)
+		case <-m.closed:
+			for {
+				select {
+				case alert := <-m.queue:
+					m.send(alert)
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Stop makes Run send the alerts left and return
+func (m *Manager) Stop(ctx context.Context) error {
+	m.close.Do(func() {
+		close(m.closed)
+	})
+	return nil
+}
+
+func (m *Manager) send(alert *Alert) {
+	for _, sink := range m.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), SEND_TIMEOUT)
+		err := sink.Send(ctx, alert)
+		cancel()
+		if err != nil {
+			sinkErrors.WithLabelValues(sink.Name()).Inc()
+			logger.With("sink", sink.Name(), "key", alert.Key).Errorf("send alert error: %s", err)
+		}
+	}
+}
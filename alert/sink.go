@@ -0,0 +1,126 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WebhookSink posts each alert as json.
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookSink(url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{url: url, headers: headers, client: &http.Client{Timeout: SEND_TIMEOUT}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Send(ctx context.Context, alert *Alert) error {
+	return postJSON(ctx, s.client, s.url, s.headers, alert)
+}
+
+// SlackSink posts alerts to a Slack incoming webhook, or any service
+// accepting its payload.
+type SlackSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackSink(url string) *SlackSink {
+	return &SlackSink{url: url, client: &http.Client{Timeout: SEND_TIMEOUT}}
+}
+
+func (s *SlackSink) Name() string {
+	return "slack"
+}
+
+var slackColors = map[string]string{
+	SEVERITY_INFO:     "#439fe0",
+	SEVERITY_WARNING:  "warning",
+	SEVERITY_CRITICAL: "danger",
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+func (s *SlackSink) Send(ctx context.Context, alert *Alert) error {
+	state := strings.ToUpper(alert.Severity)
+	color := slackColors[alert.Severity]
+	if alert.Resolved {
+		state, color = "RESOLVED", "good"
+	}
+	text := fmt.Sprintf("[%s] %s", state, alert.Title)
+	attachment := slackAttachment{
+		Fallback: text + ": " + alert.Message,
+		Color:    color,
+		Title:    alert.Title,
+		Text:     alert.Message,
+		Ts:       alert.Time.Unix(),
+	}
+	keys := make([]string, 0, len(alert.Fields))
+	for k := range alert.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attachment.Fields = append(attachment.Fields, slackField{Title: k, Value: alert.Fields[k], Short: true})
+	}
+	if alert.Suppressed != 0 {
+		attachment.Fields = append(attachment.Fields, slackField{Title: "suppressed", Value: fmt.Sprint(alert.Suppressed), Short: true})
+	}
+	return postJSON(ctx, s.client, s.url, nil, &slackPayload{Text: text, Attachments: []slackAttachment{attachment}})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("postJSON, json.Marshal error: %s", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("postJSON, http.NewRequest error: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("postJSON, post error: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("postJSON, status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
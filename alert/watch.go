@@ -0,0 +1,107 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/distribute-check/events"
+)
+
+// Rules decide which events of the listener are alerts.
+type Rules struct {
+	BlockFailures int // consecutive failures of a block before it alerts, 0 never
+}
+
+// Watcher fires an alert for each mismatch and for blocks failing
+// repeatedly, the alert of a failing block is resolved once it is processed.
+// The listener calls it directly rather than through the lossy bus, alerts
+// wait for room in the queue of the manager instead of being dropped.
+type Watcher struct {
+	manager *Manager
+	rules   Rules
+	lock    sync.Mutex
+	failing map[uint64]bool
+}
+
+func (m *Manager) NewWatcher(rules Rules) *Watcher {
+	return &Watcher{manager: m, rules: rules, failing: make(map[uint64]bool)}
+}
+
+func (w *Watcher) Mismatch(ctx context.Context, mismatch *events.Mismatch) {
+	w.manager.FireWait(ctx, mismatchAlert(mismatch))
+}
+
+func (w *Watcher) BlockFailed(ctx context.Context, failed *events.BlockFailed) {
+	if w.rules.BlockFailures <= 0 || failed.Attempts < w.rules.BlockFailures {
+		return
+	}
+	w.lock.Lock()
+	w.failing[failed.Height] = true
+	w.lock.Unlock()
+	w.manager.FireWait(ctx, &Alert{
+		Key:      blockFailedKey(failed.Height),
+		Severity: SEVERITY_CRITICAL,
+		Title:    fmt.Sprintf("Block %d failing", failed.Height),
+		Message:  fmt.Sprintf("block %d failed %d times in a row: %s", failed.Height, failed.Attempts, failed.Error),
+		Fields: map[string]string{
+			"height":   fmt.Sprint(failed.Height),
+			"attempts": fmt.Sprint(failed.Attempts),
+		},
+	})
+}
+
+func (w *Watcher) BlockProcessed(ctx context.Context, height uint64) {
+	w.lock.Lock()
+	failing := w.failing[height]
+	delete(w.failing, height)
+	w.lock.Unlock()
+	if failing {
+		w.manager.ResolveWait(ctx, blockFailedKey(height), fmt.Sprintf("Block %d processed", height),
+			fmt.Sprintf("block %d was processed after failing", height))
+	}
+}
+
+func blockFailedKey(height uint64) string {
+	return fmt.Sprintf("blockfailed/%d", height)
+}
+
+func mismatchAlert(mismatch *events.Mismatch) *Alert {
+	message := fmt.Sprintf("%s at height %d: expected %s, actual %s", mismatch.Check, mismatch.Height, mismatch.Expected, mismatch.Actual)
+	if mismatch.Detail != "" {
+		message += ", " + mismatch.Detail
+	}
+	return &Alert{
+		Key:      "mismatch/" + mismatch.Check + "/" + mismatch.Address,
+		Severity: SEVERITY_CRITICAL,
+		Title:    "Reward mismatch: " + mismatch.Check,
+		Message:  message,
+		Fields: map[string]string{
+			"height":   fmt.Sprint(mismatch.Height),
+			"check":    mismatch.Check,
+			"address":  mismatch.Address,
+			"expected": mismatch.Expected,
+			"actual":   mismatch.Actual,
+		},
+	}
+}
+
+// WatchCheck runs check every interval, an alert of key fires while it fails
+// and is resolved once it passes again.
+func (m *Manager) WatchCheck(ctx context.Context, interval time.Duration, key, severity, title string, check func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := check(ctx); err != nil {
+				m.Fire(&Alert{Key: key, Severity: severity, Title: title, Message: err.Error()})
+			} else {
+				m.Resolve(key, title+" resolved", "check passed")
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package cache provides a bounded in-process cache.
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Stats counts the activity of a cache since it was created.
+type Stats struct {
+	Size          int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+}
+
+// LRU is a cache of at most capacity entries, evicting the least recently
+// used entry when full. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[K]*list.Element
+	order    *list.List // front is the most recently used
+	stats    Stats
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		c.stats.Hits++
+		return e.Value.(*entry[K, V]).value, true
+	}
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.add(key, value)
+}
+
+// AddIf adds the entry if valid returns true, valid is called under the lock
+// of the cache so no RemoveIf runs between the check and the insert.
+func (c *LRU[K, V]) AddIf(key K, value V, valid func() bool) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !valid() {
+		return false
+	}
+	c.add(key, value)
+	return true
+}
+
+func (c *LRU[K, V]) add(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		e.Value.(*entry[K, V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry[K, V]).key)
+		c.stats.Evictions++
+	}
+}
+
+// RemoveIf removes the entries whose key matches and returns their number.
+func (c *LRU[K, V]) RemoveIf(match func(K) bool) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := 0
+	for key, e := range c.items {
+		if match(key) {
+			c.order.Remove(e)
+			delete(c.items, key)
+			n++
+		}
+	}
+	c.stats.Invalidations += uint64(n)
+	return n
+}
+
+func (c *LRU[K, V]) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Size = c.order.Len()
+	stats.Capacity = c.capacity
+	return stats
+}
@@ -0,0 +1,83 @@
+package cache
+
+import "testing"
+
+func TestLRUGetAdd(t *testing.T) {
+	c := NewLRU[string, int](2)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	c.Add("a", 1)
+	c.Add("a", 2)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Fatalf("Get(a) = %d, %v, want the updated value", v, ok)
+	}
+	stats := c.Stats()
+	if stats.Size != 1 || stats.Capacity != 2 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	// a becomes the most recently used, b is evicted next
+	c.Get("a")
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+	// updating an entry makes it the most recently used
+	c.Add("a", 4)
+	c.Add("d", 5)
+	if _, ok := c.Get("c"); ok {
+		t.Error("c not evicted after a was updated")
+	}
+	if stats := c.Stats(); stats.Size != 2 || stats.Evictions != 2 {
+		t.Errorf("stats %+v, want size 2 and 2 evictions", stats)
+	}
+}
+
+func TestLRURemoveIf(t *testing.T) {
+	c := NewLRU[int, int](10)
+	for i := 0; i < 10; i++ {
+		c.Add(i, i)
+	}
+	if n := c.RemoveIf(func(key int) bool { return key >= 6 }); n != 4 {
+		t.Fatalf("removed %d, want 4", n)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := c.Get(i); ok != (i < 6) {
+			t.Errorf("Get(%d) found %v", i, ok)
+		}
+	}
+	// removed entries free their room
+	for i := 10; i < 14; i++ {
+		c.Add(i, i)
+	}
+	if stats := c.Stats(); stats.Size != 10 || stats.Evictions != 0 || stats.Invalidations != 4 {
+		t.Errorf("stats %+v, want size 10, no eviction and 4 invalidations", stats)
+	}
+}
+
+func TestLRUAddIf(t *testing.T) {
+	c := NewLRU[string, int](2)
+	if c.AddIf("a", 1, func() bool { return false }) {
+		t.Error("added although invalid")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("invalid entry cached")
+	}
+	if !c.AddIf("a", 2, func() bool { return true }) {
+		t.Error("valid entry not added")
+	}
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Get(a) = %d, %v, want 2", v, ok)
+	}
+}
@@ -0,0 +1,114 @@
+// Package events provides an in-process publish/subscribe bus between the
+// listener and the api servers.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	TOPIC_BLOCK_PROCESSED = "blockprocessed"
+	TOPIC_MISMATCH        = "mismatch"
+	TOPIC_BLOCK_FAILED    = "blockfailed"
+)
+
+var Topics = []string{TOPIC_BLOCK_PROCESSED, TOPIC_MISMATCH, TOPIC_BLOCK_FAILED}
+
+type Event struct {
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data"`
+}
+
+// BlockProcessed is published after the listener finished a block.
+type BlockProcessed struct {
+	Height             uint64
+	TxCount            int
+	TotalGas           string
+	TotalRewards       string
+	ValidatorNum       uint64
+	AccumulatedRewards string
+}
+
+// Mismatch is published when a checked value differs from the expected one.
+type Mismatch struct {
+	Height   uint64
+	Check    string
+	Address  string
+	Expected string
+	Actual   string
+	Detail   string
+}
+
+// BlockFailed is published each time the listener fails to handle a block,
+// Attempts counts the consecutive failures of the block.
+type BlockFailed struct {
+	Height   uint64
+	Attempts int
+	Error    string
+}
+
+// Bus fans published events out to its subscriptions. Publish never blocks,
+// an event is dropped for a subscription whose buffer is full.
+type Bus struct {
+	lock   sync.RWMutex
+	nextId uint64
+	subs   map[uint64]*Subscription
+}
+
+type Subscription struct {
+	id      uint64
+	bus     *Bus
+	ch      chan Event
+	once    sync.Once
+	dropped uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[uint64]*Subscription)}
+}
+
+// Subscribe returns a subscription buffering up to size events.
+func (b *Bus) Subscribe(size int) *Subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.nextId++
+	s := &Subscription{id: b.nextId, bus: b, ch: make(chan Event, size)}
+	b.subs[s.id] = s
+	return s
+}
+
+// Publish sends the event to every subscription, a nil bus ignores it.
+func (b *Bus) Publish(topic string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, s := range b.subs {
+		select {
+		case s.ch <- Event{Topic: topic, Data: data}:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were lost because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe removes the subscription from the bus and closes its channel.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.lock.Lock()
+		delete(s.bus.subs, s.id)
+		s.bus.lock.Unlock()
+		close(s.ch)
+	})
+}
@@ -0,0 +1,288 @@
+// Package client is a typed client of the rest api.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/distribute-check/http/common"
+	"github.com/polynetwork/distribute-check/http/restful"
+	"github.com/polynetwork/distribute-check/tracing"
+)
+
+const (
+	DEFAULT_RETRIES       = 3
+	DEFAULT_RETRY_BACKOFF = 500 * time.Millisecond
+)
+
+type Config struct {
+	// Addr is the base url of the server, e.g. https://127.0.0.1:8080
+	Addr string
+	// ApiKeyId and ApiKeySecret authenticate the requests, the secret is sent
+	// in clear unless Sign is set, then requests are signed with it.
+	ApiKeyId     string
+	ApiKeySecret string
+	Sign         bool
+	// Retries of idempotent calls failing with a transient error, negative
+	// to disable
+	Retries      int
+	RetryBackoff time.Duration
+	// TLSConfig overrides the default tls config which verifies the server
+	// against the system roots
+	TLSConfig *tls.Config
+}
+
+type Client struct {
+	config *Config
+	rest   *restful.RestClient
+}
+
+func New(config *Config) *Client {
+	rest := restful.NewRestClient().SetAddr(strings.TrimRight(config.Addr, "/"))
+	if config.TLSConfig != nil {
+		rest.SetTLSConfig(config.TLSConfig)
+	}
+	return &Client{config: config, rest: rest}
+}
+
+func (this *Client) GetRewards(ctx context.Context, req *common.GetRewardsRequest) (*common.GetRewardsResponse, error) {
+	resp := new(common.GetRewardsResponse)
+	return resp, this.post(ctx, common.ACTION_GETREWARDS, common.GETREWARDS, req, resp)
+}
+
+func (this *Client) GetGasFee(ctx context.Context, req *common.GetGasFeeRequest) (*common.GetGasFeeResponse, error) {
+	resp := new(common.GetGasFeeResponse)
+	return resp, this.post(ctx, common.ACTION_GETGASFEE, common.GETGASFEE, req, resp)
+}
+
+func (this *Client) GetRewardBreakdown(ctx context.Context, req *common.GetRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error) {
+	resp := new(common.GetRewardBreakdownResponse)
+	return resp, this.post(ctx, common.ACTION_GETREWARDBREAKDOWN, common.GETREWARDBREAKDOWN, req, resp)
+}
+
+func (this *Client) ExplainRewards(ctx context.Context, req *common.ExplainRewardsRequest) (*common.ExplainRewardsResponse, error) {
+	resp := new(common.ExplainRewardsResponse)
+	return resp, this.post(ctx, common.ACTION_EXPLAINREWARDS, common.EXPLAINREWARDS, req, resp)
+}
+
+func (this *Client) AddressRewards(ctx context.Context, req *common.AddressRewardsRequest) (*common.AddressRewardsResponse, error) {
+	query := url.Values{"endHeight": {strconv.FormatUint(req.EndHeight, 10)}}
+	setAmountFormat(query, req.Unit, req.Decimals)
+	resp := new(common.AddressRewardsResponse)
+	return resp, this.get(ctx, common.ACTION_ADDRESS_REWARDS, common.V2_ADDRESS_REWARDS, req.Address, query, resp)
+}
+
+func (this *Client) AddressGasFee(ctx context.Context, req *common.AddressGasFeeRequest) (*common.AddressGasFeeResponse, error) {
+	query := url.Values{"endHeight": {strconv.FormatUint(req.EndHeight, 10)}}
+	setAmountFormat(query, req.Unit, req.Decimals)
+	resp := new(common.AddressGasFeeResponse)
+	return resp, this.get(ctx, common.ACTION_ADDRESS_GASFEE, common.V2_ADDRESS_GASFEE, req.Address, query, resp)
+}
+
+func (this *Client) AddressRewardBreakdown(ctx context.Context, req *common.AddressRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error) {
+	query := url.Values{
+		"startHeight": {strconv.FormatUint(req.StartHeight, 10)},
+		"endHeight":   {strconv.FormatUint(req.EndHeight, 10)},
+	}
+	setAmountFormat(query, req.Unit, req.Decimals)
+	resp := new(common.GetRewardBreakdownResponse)
+	return resp, this.get(ctx, common.ACTION_ADDRESS_BREAKDOWN, common.V2_ADDRESS_BREAKDOWN, req.Address, query, resp)
+}
+
+func (this *Client) AddressRewardsExplain(ctx context.Context, req *common.AddressRewardsExplainRequest) (*common.ExplainRewardsResponse, error) {
+	query := url.Values{"height": {strconv.FormatUint(req.Height, 10)}}
+	resp := new(common.ExplainRewardsResponse)
+	return resp, this.get(ctx, common.ACTION_ADDRESS_EXPLAIN, common.V2_ADDRESS_EXPLAIN, req.Address, query, resp)
+}
+
+func (this *Client) CacheStats(ctx context.Context) (*common.CacheStatsResponse, error) {
+	resp := new(common.CacheStatsResponse)
+	return resp, this.call(ctx, common.ACTION_CACHE_STATS, http.MethodGet, common.V2_CACHE_STATS, nil, true, resp)
+}
+
+func (this *Client) Status(ctx context.Context) (*common.StatusResponse, error) {
+	resp := new(common.StatusResponse)
+	return resp, this.call(ctx, common.ACTION_STATUS, http.MethodGet, common.V2_STATUS, nil, true, resp)
+}
+
+func (this *Client) Validators(ctx context.Context, req *common.ValidatorsRequest) (*common.ValidatorsResponse, error) {
+	query := url.Values{}
+	setAmountFormat(query, req.Unit, req.Decimals)
+	resp := new(common.ValidatorsResponse)
+	return resp, this.call(ctx, common.ACTION_VALIDATORS, http.MethodGet, withQuery(common.V2_VALIDATORS, query), nil, true, resp)
+}
+
+func (this *Client) Blocks(ctx context.Context, req *common.BlocksRequest) (*common.BlocksResponse, error) {
+	query := url.Values{}
+	setLimit(query, req.Limit)
+	setAmountFormat(query, req.Unit, req.Decimals)
+	resp := new(common.BlocksResponse)
+	return resp, this.call(ctx, common.ACTION_BLOCKS, http.MethodGet, withQuery(common.V2_BLOCKS, query), nil, true, resp)
+}
+
+func (this *Client) BlockDust(ctx context.Context, req *common.BlockDustRequest) (*common.BlockDustResponse, error) {
+	query := url.Values{}
+	if req.Validator != "" {
+		query.Set("validator", req.Validator)
+	}
+	path := strings.Replace(common.V2_BLOCK_DUST, ":height", strconv.FormatUint(req.Height, 10), 1)
+	resp := new(common.BlockDustResponse)
+	return resp, this.call(ctx, common.ACTION_BLOCK_DUST, http.MethodGet, withQuery(path, query), nil, true, resp)
+}
+
+func (this *Client) Mismatches(ctx context.Context, req *common.MismatchesRequest) (*common.MismatchesResponse, error) {
+	query := url.Values{}
+	setLimit(query, req.Limit)
+	resp := new(common.MismatchesResponse)
+	return resp, this.call(ctx, common.ACTION_MISMATCHES, http.MethodGet, withQuery(common.V2_MISMATCHES, query), nil, true, resp)
+}
+
+func (this *Client) GetLogLevels(ctx context.Context) (*common.LogLevelsResponse, error) {
+	resp := new(common.LogLevelsResponse)
+	return resp, this.call(ctx, common.ACTION_GET_LOG_LEVELS, http.MethodGet, common.V2_LOG_LEVELS, nil, true, resp)
+}
+
+// SetLogLevels changes the log levels of the server, it needs an admin key.
+// Setting the same levels again changes nothing, so the call is retried.
+func (this *Client) SetLogLevels(ctx context.Context, req *common.SetLogLevelsRequest) (*common.LogLevelsResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s, json.Marshal error: %s", common.ACTION_SET_LOG_LEVELS, err)
+	}
+	resp := new(common.LogLevelsResponse)
+	return resp, this.call(ctx, common.ACTION_SET_LOG_LEVELS, http.MethodPut, common.V2_LOG_LEVELS, data, true, resp)
+}
+
+// WsToken issues a single use token for a websocket upgrade by a client that
+// can not set the authentication headers on it.
+func (this *Client) WsToken(ctx context.Context) (*common.WsTokenResponse, error) {
+	resp := new(common.WsTokenResponse)
+	return resp, this.call(ctx, common.ACTION_WS_TOKEN, http.MethodPost, common.V2_WS_TOKEN, nil, false, resp)
+}
+
+// setAmountFormat adds the amount format options of a v2 request
+func setAmountFormat(query url.Values, unit string, decimals *uint64) {
+	if unit != "" {
+		query.Set("unit", unit)
+	}
+	if decimals != nil {
+		query.Set("decimals", strconv.FormatUint(*decimals, 10))
+	}
+}
+
+// setLimit adds the limit of a listing, the server default if 0
+func setLimit(query url.Values, limit uint64) {
+	if limit != 0 {
+		query.Set("limit", strconv.FormatUint(limit, 10))
+	}
+}
+
+// withQuery appends the query to the path, if any
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// post calls a v1 action, the actions are read only queries so the call is
+// idempotent.
+func (this *Client) post(ctx context.Context, action, path string, req, resp interface{}) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("%s, json.Marshal error: %s", action, err)
+	}
+	return this.call(ctx, action, http.MethodPost, path, data, true, resp)
+}
+
+// get calls a v2 action on an address
+func (this *Client) get(ctx context.Context, action, path, address string, query url.Values, resp interface{}) error {
+	uri := strings.Replace(path, ":addr", url.PathEscape(address), 1) + "?" + query.Encode()
+	return this.call(ctx, action, http.MethodGet, uri, nil, true, resp)
+}
+
+// call sends the request, retrying transient failures of idempotent calls
+// with an exponential backoff.
+func (this *Client) call(ctx context.Context, action, method, uri string, data []byte, idempotent bool, resp interface{}) error {
+	retries := this.config.Retries
+	if retries == 0 {
+		retries = DEFAULT_RETRIES
+	}
+	if !idempotent || retries < 0 {
+		retries = 0
+	}
+	backoff := this.config.RetryBackoff
+	if backoff <= 0 {
+		backoff = DEFAULT_RETRY_BACKOFF
+	}
+	for attempt := 0; ; attempt++ {
+		err := this.send(ctx, action, method, uri, data, resp)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(backoff << attempt):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (this *Client) send(ctx context.Context, action, method, uri string, data []byte, resp interface{}) error {
+	header := this.header(method, uri, data)
+	// the server traces the request as part of the span of ctx
+	tracing.Inject(ctx, header)
+	status, body, err := this.rest.Do(ctx, method, this.rest.Addr+uri, header, data)
+	if err != nil {
+		return &transportError{err: fmt.Errorf("%s, %w", action, err)}
+	}
+	result := new(response)
+	if err := json.Unmarshal(body, result); err != nil {
+		return &StatusError{Action: action, Status: status, Body: string(body)}
+	}
+	if result.Error != restful.SUCCESS {
+		return newError(action, status, result)
+	}
+	if err := json.Unmarshal(result.Result, resp); err != nil {
+		return fmt.Errorf("%s, json.Unmarshal result error: %s", action, err)
+	}
+	return nil
+}
+
+// header returns the authentication headers of the request
+func (this *Client) header(method, uri string, data []byte) http.Header {
+	header := make(http.Header)
+	if this.config.ApiKeyId == "" {
+		return header
+	}
+	header.Set(restful.API_KEY_ID_HEADER, this.config.ApiKeyId)
+	if !this.config.Sign {
+		header.Set(restful.API_KEY_HEADER, this.config.ApiKeySecret)
+		return header
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	var buf [16]byte
+	rand.Read(buf[:])
+	nonce := hex.EncodeToString(buf[:])
+	signature := restful.Sign(this.config.ApiKeySecret, method, uri, timestamp, nonce, data)
+	header.Set(restful.TIMESTAMP_HEADER, timestamp)
+	header.Set(restful.NONCE_HEADER, nonce)
+	header.Set(restful.SIGNATURE_HEADER, hex.EncodeToString(signature))
+	return header
+}
+
+type response struct {
+	Action string          `json:"action"`
+	Desc   string          `json:"desc"`
+	Error  uint32          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/polynetwork/distribute-check/http/openapi"
+	"github.com/polynetwork/distribute-check/http/restful"
+)
+
+// Error is an error code returned by the server, compare it to the Err
+// values with errors.Is.
+type Error struct {
+	Action string
+	Status int
+	Code   uint32
+	Desc   string
+	// Fields are the invalid fields of an INVALID_PARAMS error
+	Fields []openapi.FieldError
+}
+
+var (
+	ErrFailed            = &Error{Code: restful.FAILED}
+	ErrInvalidMethod     = &Error{Code: restful.INVALID_METHOD}
+	ErrInvalidParams     = &Error{Code: restful.INVALID_PARAMS}
+	ErrIllegalDataFormat = &Error{Code: restful.ILLEGAL_DATAFORMAT}
+	ErrInternal          = &Error{Code: restful.INTERNAL_ERROR}
+	ErrRequestTooLarge   = &Error{Code: restful.REQUEST_TOO_LARGE}
+	ErrRequestTimeout    = &Error{Code: restful.REQUEST_TIMEOUT}
+	ErrUnauthorized      = &Error{Code: restful.UNAUTHORIZED}
+	ErrForbidden         = &Error{Code: restful.FORBIDDEN}
+	ErrRateLimited       = &Error{Code: restful.RATE_LIMITED}
+	ErrQueryTooExpensive = &Error{Code: restful.QUERY_TOO_EXPENSIVE}
+)
+
+func newError(action string, status int, resp *response) *Error {
+	e := &Error{Action: action, Status: status, Code: resp.Error}
+	_ = json.Unmarshal(resp.Result, &e.Desc)
+	// v1 actions answer the message of an error in desc
+	if e.Desc == "" && resp.Desc != restful.ErrMap[resp.Error] {
+		e.Desc = resp.Desc
+	}
+	if e.Code == restful.INVALID_PARAMS {
+		_ = json.Unmarshal(resp.Result, &e.Fields)
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s, error %d %s", e.Action, e.Code, restful.ErrMap[e.Code])
+	if e.Desc != "" {
+		msg += ": " + e.Desc
+	}
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// StatusError is a response which is not an api response, e.g. from a proxy
+type StatusError struct {
+	Action string
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s, unexpected response status %d: %.200s", e.Action, e.Status, e.Body)
+}
+
+// transportError is a failure to send the request or read the response
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports if the call may succeed when sent again
+func retryable(err error) bool {
+	var transport *transportError
+	if errors.As(err, &transport) {
+		// a server failing verification will not pass it on retry
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalid x509.CertificateInvalidError
+		return !errors.As(err, &unknownAuthority) && !errors.As(err, &hostname) && !errors.As(err, &invalid) &&
+			!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Status == http.StatusBadGateway || status.Status == http.StatusServiceUnavailable ||
+			status.Status == http.StatusGatewayTimeout
+	}
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrRequestTimeout)
+}
@@ -6,6 +6,66 @@ const (
 
 	GETGASFEE        = "/api/v1/getgasfee"
 	ACTION_GETGASFEE = "getgasfee"
+
+	GETREWARDBREAKDOWN        = "/api/v1/getrewardbreakdown"
+	ACTION_GETREWARDBREAKDOWN = "getrewardbreakdown"
+
+	EXPLAINREWARDS        = "/api/v1/explainrewards"
+	ACTION_EXPLAINREWARDS = "explainrewards"
+
+	WEBSOCKET = "/api/v1/ws"
+
+	V2_WS_TOKEN     = "/api/v2/ws/token"
+	ACTION_WS_TOKEN = "wstoken"
+
+	OPENAPI = "/api/v1/openapi.json"
+
+	METRICS = "/metrics"
+
+	HEALTHZ = "/healthz"
+	READYZ  = "/readyz"
+
+	PROBE_OK   = "ok"
+	PROBE_FAIL = "fail"
+
+	V2_ADDRESS_REWARDS     = "/api/v2/addresses/:addr/rewards"
+	ACTION_ADDRESS_REWARDS = "addressrewards"
+
+	V2_ADDRESS_GASFEE     = "/api/v2/addresses/:addr/gasfee"
+	ACTION_ADDRESS_GASFEE = "addressgasfee"
+
+	V2_ADDRESS_BREAKDOWN     = "/api/v2/addresses/:addr/breakdown"
+	ACTION_ADDRESS_BREAKDOWN = "addressbreakdown"
+
+	V2_ADDRESS_EXPLAIN     = "/api/v2/addresses/:addr/explain"
+	ACTION_ADDRESS_EXPLAIN = "addressexplain"
+
+	V2_CACHE_STATS     = "/api/v2/cache/stats"
+	ACTION_CACHE_STATS = "cachestats"
+
+	V2_STATUS     = "/api/v2/status"
+	ACTION_STATUS = "status"
+
+	V2_VALIDATORS     = "/api/v2/validators"
+	ACTION_VALIDATORS = "validators"
+
+	V2_BLOCKS     = "/api/v2/blocks"
+	ACTION_BLOCKS = "blocks"
+
+	V2_BLOCK_DUST     = "/api/v2/blocks/:height/dust"
+	ACTION_BLOCK_DUST = "blockdust"
+
+	V2_MISMATCHES     = "/api/v2/mismatches"
+	ACTION_MISMATCHES = "mismatches"
+
+	DASHBOARD = "/dashboard"
+
+	DEFAULT_LIST_LIMIT = 20
+	MAX_LIST_LIMIT     = 100
+
+	V2_LOG_LEVELS         = "/api/v2/admin/loglevels"
+	ACTION_GET_LOG_LEVELS = "getloglevels"
+	ACTION_SET_LOG_LEVELS = "setloglevels"
 )
 
 type Response struct {
@@ -17,22 +77,273 @@ type Response struct {
 
 type GetRewardsRequest struct {
 	Id        string
-	Addresses []string
-	EndHeight uint64
+	Addresses []string `validate:"required"`
+	EndHeight uint64   `validate:"required"`
+	// amounts are given in wei unless Unit is "znt", Decimals then rounds them
+	Unit     string
+	Decimals *uint64 `json:",omitempty"`
 }
 
 type GetRewardsResponse struct {
 	Id     string
+	Unit   string
 	Amount []string
 }
 
 type GetGasFeeRequest struct {
 	Id        string
-	Addresses []string
-	EndHeight uint64
+	Addresses []string `validate:"required"`
+	EndHeight uint64   `validate:"required"`
+	Unit      string
+	Decimals  *uint64 `json:",omitempty"`
 }
 
 type GetGasFeeResponse struct {
 	Id     string
+	Unit   string
 	Amount []string
 }
+
+type GetRewardBreakdownRequest struct {
+	Id          string
+	Address     string `validate:"required"`
+	StartHeight uint64
+	EndHeight   uint64 `validate:"required"`
+	Unit        string
+	Decimals    *uint64 `json:",omitempty"`
+}
+
+type RewardDetail struct {
+	Validator string
+	Height    uint64
+	Kind      string
+	Amount    string
+}
+
+type GetRewardBreakdownResponse struct {
+	Id      string
+	Address string
+	Unit    string
+	Details []RewardDetail
+}
+
+type ExplainRewardsRequest struct {
+	Id      string
+	Address string `validate:"required"`
+	Height  uint64 `validate:"required"`
+}
+
+type ValidatorRewardsExplain struct {
+	Validator        string
+	StakeAddress     string
+	CommissionRate   string
+	TotalStake       string
+	Commission       string
+	StakeRewards     string
+	RewardsPerToken  string
+	Stake            string
+	StakeAmount      string
+	CommissionAmount string
+}
+
+type ExplainRewardsResponse struct {
+	Id                 string
+	Address            string
+	Height             uint64
+	BlockRewards       string
+	CommunityRate      string
+	TotalGas           string
+	AccumulatedRewards string
+	TotalRewards       string
+	ValidatorNum       uint64
+	ValidatorRewards   string
+	Validators         []ValidatorRewardsExplain
+	Amount             string
+	StoredAmount       string
+	Consistent         bool
+}
+
+type AddressRewardsRequest struct {
+	Address   string  `path:"addr"`
+	EndHeight uint64  `query:"endHeight" validate:"required"`
+	Unit      string  `query:"unit"`
+	Decimals  *uint64 `query:"decimals"`
+}
+
+type AddressRewardsResponse struct {
+	Address   string
+	EndHeight uint64
+	Unit      string
+	Amount    string
+}
+
+type AddressGasFeeRequest struct {
+	Address   string  `path:"addr"`
+	EndHeight uint64  `query:"endHeight" validate:"required"`
+	Unit      string  `query:"unit"`
+	Decimals  *uint64 `query:"decimals"`
+}
+
+type AddressGasFeeResponse struct {
+	Address   string
+	EndHeight uint64
+	Unit      string
+	Amount    string
+}
+
+type AddressRewardBreakdownRequest struct {
+	Address     string  `path:"addr"`
+	StartHeight uint64  `query:"startHeight"`
+	EndHeight   uint64  `query:"endHeight" validate:"required"`
+	Unit        string  `query:"unit"`
+	Decimals    *uint64 `query:"decimals"`
+}
+
+type AddressRewardsExplainRequest struct {
+	Address string `path:"addr"`
+	Height  uint64 `query:"height" validate:"required"`
+}
+
+type CacheStatsRequest struct {
+}
+
+type CacheStatsResponse struct {
+	Enabled       bool
+	Size          int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+}
+
+type WsTokenRequest struct {
+}
+
+// WsTokenResponse is a single use token authenticating a websocket upgrade
+// in its token query parameter until Expires, a unix timestamp.
+type WsTokenResponse struct {
+	Token   string
+	Expires int64
+}
+
+type StatusRequest struct {
+}
+
+// StatusResponse is the sync progress of the listener, LastProgress is empty
+// before the first block is handled.
+type StatusResponse struct {
+	Head         uint64
+	Handled      uint64
+	Lag          uint64
+	LastProgress string
+	EpochId      uint64
+}
+
+type ValidatorsRequest struct {
+	Unit     string  `query:"unit"`
+	Decimals *uint64 `query:"decimals"`
+}
+
+type ValidatorInfo struct {
+	ConsensusAddress string
+	StakeAddress     string
+	Commission       string
+	TotalStake       string
+	SelfStake        string
+	Delegators       int
+}
+
+// ValidatorsResponse is the validator set of the current epoch
+type ValidatorsResponse struct {
+	EpochId    uint64
+	Unit       string
+	Validators []ValidatorInfo
+}
+
+// BlocksRequest lists the latest blocks rewards were calculated at, Limit
+// defaults to DEFAULT_LIST_LIMIT and is at most MAX_LIST_LIMIT.
+type BlocksRequest struct {
+	Limit    uint64  `query:"limit"`
+	Unit     string  `query:"unit"`
+	Decimals *uint64 `query:"decimals"`
+}
+
+type BlockSummary struct {
+	Height             uint64
+	TotalGas           string
+	BlockRewards       string
+	AccumulatedRewards string
+	TotalRewards       string
+	ValidatorNum       uint64
+}
+
+type BlocksResponse struct {
+	Unit   string
+	Blocks []BlockSummary
+}
+
+type BlockDustRequest struct {
+	Height    uint64 `path:"height"`
+	Validator string `query:"validator"`
+}
+
+// RewardDust is what a stage of the distribution truncated, in wei with up
+// to 18 decimals. The split stage is of the whole block and has no Validator.
+type RewardDust struct {
+	Validator string
+	Stage     string
+	Amount    string
+}
+
+type BlockDustResponse struct {
+	Height uint64
+	Total  string
+	Dust   []RewardDust
+}
+
+type MismatchesRequest struct {
+	Limit uint64 `query:"limit"`
+}
+
+type Mismatch struct {
+	Height   uint64
+	Check    string
+	Address  string
+	Expected string
+	Actual   string
+	Detail   string
+	Time     string
+}
+
+// MismatchesResponse lists the latest mismatches first
+type MismatchesResponse struct {
+	Mismatches []Mismatch
+}
+
+type GetLogLevelsRequest struct {
+}
+
+// SetLogLevelsRequest changes the global level if set, and the level of each
+// component, an empty level makes the component log at the global level.
+type SetLogLevelsRequest struct {
+	Level      string            `json:",omitempty"`
+	Components map[string]string `json:",omitempty"`
+}
+
+type LogLevelsResponse struct {
+	Level      string
+	Components map[string]string
+}
+
+type ProbeResponse struct {
+	Status string
+	Checks []ProbeCheck
+}
+
+type ProbeCheck struct {
+	Name     string
+	Status   string
+	Error    string `json:",omitempty"`
+	Duration string
+}
@@ -0,0 +1,172 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+
+	"github.com/polynetwork/distribute-check/http/common"
+)
+
+const (
+	OPENAPI_VERSION = "3.0.3"
+	CONTENT_JSON    = "application/json"
+	CONTENT_CSV     = "text/csv"
+	CONTENT_NDJSON  = "application/x-ndjson"
+)
+
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+}
+
+type Operation struct {
+	OperationId string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func NewDocument(title, version string) *Document {
+	return &Document{
+		OpenAPI: OPENAPI_VERSION,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]*PathItem),
+	}
+}
+
+// AddPost documents a post action taking req as body and answering
+// common.Response with resp as result.
+func (d *Document) AddPost(path, action string, req, resp interface{}) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = new(PathItem)
+		d.Paths[path] = item
+	}
+	item.Post = &Operation{
+		OperationId: action,
+		RequestBody: &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{CONTENT_JSON: {Schema: SchemaOf(req)}},
+		},
+		Responses: map[string]*Response{"200": responseOf(resp)},
+	}
+}
+
+// AddGet documents a get action without parameters.
+func (d *Document) AddGet(path, action string, resp interface{}) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = new(PathItem)
+		d.Paths[path] = item
+	}
+	item.Get = &Operation{
+		OperationId: action,
+		Responses:   map[string]*Response{"200": responseOf(resp)},
+	}
+}
+
+// AddTyped documents a typed action, whose request fields tagged `path` and
+// `query` are parameters. Router params like :addr become {addr}.
+func (d *Document) AddTyped(method, path, action string, req, resp interface{}) {
+	path = routeParam.ReplaceAllString(path, "{$1}")
+	item, ok := d.Paths[path]
+	if !ok {
+		item = new(PathItem)
+		d.Paths[path] = item
+	}
+	op := &Operation{
+		OperationId: action,
+		Parameters:  parametersOf(req),
+		Responses:   map[string]*Response{"200": responseOf(resp)},
+	}
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPost:
+		item.Post = op
+	}
+}
+
+// AddFormats documents that the action can also answer csv and ndjson rows,
+// negotiated by the Accept header or the format query parameter.
+func (d *Document) AddFormats(method, path string) {
+	item, ok := d.Paths[routeParam.ReplaceAllString(path, "{$1}")]
+	if !ok {
+		return
+	}
+	op := item.Post
+	if method == http.MethodGet {
+		op = item.Get
+	}
+	if op == nil {
+		return
+	}
+	op.Parameters = append(op.Parameters, &Parameter{Name: "format", In: "query", Schema: &Schema{Type: TYPE_STRING}})
+	rows := &MediaType{Schema: &Schema{Type: TYPE_STRING}}
+	resp := op.Responses["200"]
+	resp.Content[CONTENT_CSV] = rows
+	resp.Content[CONTENT_NDJSON] = rows
+	resp.Description += ", or rows for format csv and ndjson"
+}
+
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+func parametersOf(req interface{}) []*Parameter {
+	params := make([]*Parameter, 0)
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := f.Tag.Get("path"); name != "" {
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: schemaOf(f.Type)})
+		} else if name := f.Tag.Get("query"); name != "" {
+			params = append(params, &Parameter{Name: name, In: "query",
+				Required: f.Tag.Get("validate") == "required", Schema: schemaOf(f.Type)})
+		}
+	}
+	return params
+}
+
+func responseOf(resp interface{}) *Response {
+	schema := SchemaOf(common.Response{})
+	schema.Properties["result"] = SchemaOf(resp)
+	return &Response{
+		Description: "error is 0 on success, result holds the response",
+		Content:     map[string]*MediaType{CONTENT_JSON: {Schema: schema}},
+	}
+}
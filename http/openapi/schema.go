@@ -0,0 +1,248 @@
+// Package openapi builds the OpenAPI 3 document of the api from the request
+// and response structs, and validates requests against it.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TYPE_OBJECT  = "object"
+	TYPE_ARRAY   = "array"
+	TYPE_STRING  = "string"
+	TYPE_INTEGER = "integer"
+	TYPE_NUMBER  = "number"
+	TYPE_BOOLEAN = "boolean"
+)
+
+// Schema is the subset of the OpenAPI schema object used by the api.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// FieldError describes why the value of a field is invalid.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// SchemaOf returns the schema of the type of v. Struct fields are named as
+// encoding/json names them, a `validate:"required"` tag marks them required
+// and unknown properties are rejected.
+func SchemaOf(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	zero := float64(0)
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: TYPE_STRING}
+	case reflect.Bool:
+		return &Schema{Type: TYPE_BOOLEAN}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: TYPE_INTEGER, Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: TYPE_INTEGER, Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: TYPE_INTEGER, Format: "int32", Minimum: &zero}
+	case reflect.Uint64:
+		return &Schema{Type: TYPE_INTEGER, Format: "int64", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TYPE_NUMBER}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TYPE_ARRAY, Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: TYPE_OBJECT}
+	case reflect.Struct:
+		additional := false
+		s := &Schema{Type: TYPE_OBJECT, Properties: make(map[string]*Schema), AdditionalProperties: &additional}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			s.Properties[name] = schemaOf(f.Type)
+			if f.Tag.Get("validate") == "required" {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	}
+	return &Schema{}
+}
+
+// Validate checks a decoded json value against the schema. Numbers may be
+// float64 or json.Number, property names match case-insensitively like
+// encoding/json does.
+func (s *Schema) Validate(value interface{}) []FieldError {
+	errs := make([]FieldError, 0)
+	s.validate("", value, &errs)
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func (s *Schema) validate(field string, value interface{}, errs *[]FieldError) {
+	fail := func(format string, a ...interface{}) {
+		name := field
+		if name == "" {
+			name = "$"
+		}
+		*errs = append(*errs, FieldError{Field: name, Message: fmt.Sprintf(format, a...)})
+	}
+	if value == nil {
+		if s.Type != "" {
+			fail("expected %s, got null", s.Type)
+		}
+		return
+	}
+	switch s.Type {
+	case TYPE_STRING:
+		if _, ok := value.(string); !ok {
+			fail("expected string, got %s", jsonType(value))
+		}
+	case TYPE_BOOLEAN:
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(value))
+		}
+	case TYPE_NUMBER:
+		if jsonType(value) != TYPE_NUMBER {
+			fail("expected number, got %s", jsonType(value))
+		}
+	case TYPE_INTEGER:
+		s.validateInteger(value, fail)
+	case TYPE_ARRAY:
+		list, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonType(value))
+			return
+		}
+		for i, item := range list {
+			s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, errs)
+		}
+	case TYPE_OBJECT:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonType(value))
+			return
+		}
+		if s.Properties == nil {
+			return
+		}
+		seen := make(map[string]bool)
+		for key, v := range obj {
+			name, prop := s.property(key)
+			path := key
+			if field != "" {
+				path = field + "." + key
+			}
+			if prop == nil {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, FieldError{Field: path, Message: "unknown field"})
+				}
+				continue
+			}
+			seen[name] = true
+			prop.validate(path, v, errs)
+		}
+		for _, name := range s.Required {
+			if !seen[name] {
+				path := name
+				if field != "" {
+					path = field + "." + name
+				}
+				*errs = append(*errs, FieldError{Field: path, Message: "required field missing"})
+			}
+		}
+	}
+}
+
+func (s *Schema) validateInteger(value interface{}, fail func(string, ...interface{})) {
+	unsigned := s.Minimum != nil && *s.Minimum >= 0
+	switch v := value.(type) {
+	case json.Number:
+		var err error
+		if unsigned {
+			_, err = strconv.ParseUint(string(v), 10, 64)
+		} else {
+			_, err = strconv.ParseInt(string(v), 10, 64)
+		}
+		if err != nil {
+			fail("expected %s, got %s", integerName(unsigned), string(v))
+		}
+	case float64:
+		if v != math.Trunc(v) || (unsigned && v < 0) {
+			fail("expected %s, got %v", integerName(unsigned), v)
+		}
+	default:
+		fail("expected %s, got %s", integerName(unsigned), jsonType(value))
+	}
+}
+
+func (s *Schema) property(key string) (string, *Schema) {
+	if prop, ok := s.Properties[key]; ok {
+		return key, prop
+	}
+	for name, prop := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return name, prop
+		}
+	}
+	return "", nil
+}
+
+func integerName(unsigned bool) string {
+	if unsigned {
+		return "unsigned integer"
+	}
+	return "integer"
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return TYPE_STRING
+	case bool:
+		return TYPE_BOOLEAN
+	case float64, json.Number:
+		return TYPE_NUMBER
+	case []interface{}:
+		return TYPE_ARRAY
+	case map[string]interface{}:
+		return TYPE_OBJECT
+	}
+	return fmt.Sprintf("%T", value)
+}
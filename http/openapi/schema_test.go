@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testInner struct {
+	Height uint64 `validate:"required"`
+}
+
+type testRequest struct {
+	Address   string `json:"address" validate:"required"`
+	Addresses []string
+	EndHeight uint64
+	Offset    int64
+	Ratio     float64
+	Flag      bool
+	Inner     *testInner `json:"inner,omitempty"`
+	Skipped   string     `json:"-"`
+	hidden    string
+}
+
+// decode decodes body like the server does, with json.Number if useNumber
+func decode(t *testing.T, body string, useNumber bool) interface{} {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(body)))
+	if useNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("decode %s: %s", body, err)
+	}
+	return value
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(new(testRequest))
+	if s.Type != TYPE_OBJECT || s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Fatalf("schema %+v, want a closed object", s)
+	}
+	names := make([]string, 0)
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	if len(names) != 7 {
+		t.Errorf("properties %v, want the 7 exported json fields", names)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"address"}) {
+		t.Errorf("required %v", s.Required)
+	}
+	tests := []struct {
+		name     string
+		typ      string
+		format   string
+		unsigned bool
+	}{
+		{"address", TYPE_STRING, "", false},
+		{"Addresses", TYPE_ARRAY, "", false},
+		{"EndHeight", TYPE_INTEGER, "int64", true},
+		{"Offset", TYPE_INTEGER, "int64", false},
+		{"Ratio", TYPE_NUMBER, "", false},
+		{"Flag", TYPE_BOOLEAN, "", false},
+		{"inner", TYPE_OBJECT, "", false},
+	}
+	for _, test := range tests {
+		prop := s.Properties[test.name]
+		if prop == nil {
+			t.Errorf("%s missing", test.name)
+			continue
+		}
+		if prop.Type != test.typ || prop.Format != test.format || (prop.Minimum != nil) != test.unsigned {
+			t.Errorf("%s schema %+v, want %s %s unsigned %v", test.name, prop, test.typ, test.format, test.unsigned)
+		}
+	}
+	if items := s.Properties["Addresses"].Items; items == nil || items.Type != TYPE_STRING {
+		t.Errorf("array items %+v, want strings", items)
+	}
+	if inner := s.Properties["inner"]; !reflect.DeepEqual(inner.Required, []string{"Height"}) {
+		t.Errorf("nested required %v", inner.Required)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		errs []string // for json.Number and float64 decoding alike
+	}{
+		{"valid", `{"address":"0xa","Addresses":["0xb"],"EndHeight":10,"Offset":-1,"Ratio":0.5,"Flag":true,"inner":{"Height":1}}`, nil},
+		{"case insensitive", `{"ADDRESS":"0xa","endheight":10}`, nil},
+		{"required missing", `{"EndHeight":10}`, []string{"address: required field missing"}},
+		{"required null", `{"address":null}`, []string{"address: expected string, got null"}},
+		{"not an object", `[]`, []string{"$: expected object, got array"}},
+		{"wrong type", `{"address":1}`, []string{"address: expected string, got number"}},
+		{"integer as string", `{"address":"0xa","EndHeight":"10"}`, []string{"EndHeight: expected unsigned integer, got string"}},
+		{"fraction", `{"address":"0xa","Offset":1.5}`, []string{"Offset: expected integer, got 1.5"}},
+		{"negative unsigned", `{"address":"0xa","EndHeight":-1}`, []string{"EndHeight: expected unsigned integer, got -1"}},
+		{"number as string", `{"address":"0xa","Ratio":"1"}`, []string{"Ratio: expected number, got string"}},
+		{"boolean", `{"address":"0xa","Flag":1}`, []string{"Flag: expected boolean, got number"}},
+		{"array", `{"address":"0xa","Addresses":"0xb"}`, []string{"Addresses: expected array, got string"}},
+		{"array items", `{"address":"0xa","Addresses":["0xb",2,"0xc",false]}`, []string{
+			"Addresses[1]: expected string, got number",
+			"Addresses[3]: expected string, got boolean",
+		}},
+		{"unknown field", `{"address":"0xa","Other":1}`, []string{"Other: unknown field"}},
+		{"ignored fields", `{"address":"0xa","Skipped":"x","hidden":"x"}`, []string{"Skipped: unknown field", "hidden: unknown field"}},
+		{"nested", `{"address":"0xa","inner":{"Other":1}}`, []string{"inner.Height: required field missing", "inner.Other: unknown field"}},
+		{"several", `{"EndHeight":true,"Other":1}`, []string{
+			"EndHeight: expected unsigned integer, got boolean",
+			"Other: unknown field",
+			"address: required field missing",
+		}},
+	}
+	s := SchemaOf(new(testRequest))
+	for _, test := range tests {
+		for _, useNumber := range []bool{true, false} {
+			errs := s.Validate(decode(t, test.body, useNumber))
+			got := make([]string, 0, len(errs))
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if len(got) != len(test.errs) || (len(got) != 0 && !reflect.DeepEqual(got, test.errs)) {
+				t.Errorf("%s with json.Number %v: errors %q, want %q", test.name, useNumber, got, test.errs)
+			}
+		}
+	}
+}
+
+func TestValidateNumbers(t *testing.T) {
+	s := SchemaOf(new(testRequest))
+	// exact with json.Number only, float64 loses the precision
+	body := `{"address":"0xa","EndHeight":18446744073709551615,"Offset":-9223372036854775808}`
+	if errs := s.Validate(decode(t, body, true)); len(errs) != 0 {
+		t.Errorf("limits of the integer types rejected: %v", errs)
+	}
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"unsigned overflow", `{"address":"0xa","EndHeight":18446744073709551616}`, "EndHeight"},
+		{"signed overflow", `{"address":"0xa","Offset":9223372036854775808}`, "Offset"},
+		{"exponent", `{"address":"0xa","Offset":1e3}`, "Offset"},
+	}
+	for _, test := range tests {
+		errs := s.Validate(decode(t, test.body, true))
+		if len(errs) != 1 || errs[0].Field != test.field {
+			t.Errorf("%s: errors %v, want one of %s", test.name, errs, test.field)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SCOPE_READ  = "read"
+	SCOPE_ADMIN = "admin"
+
+	API_KEY_ID_HEADER = "X-Api-Key-Id"
+	API_KEY_HEADER    = "X-Api-Key"
+	TIMESTAMP_HEADER  = "X-Timestamp"
+	NONCE_HEADER      = "X-Nonce"
+	SIGNATURE_HEADER  = "X-Signature"
+
+	MAX_SIGNATURE_SKEW = 5 * time.Minute
+	MAX_NONCE_LENGTH   = 64
+
+	WS_TOKEN_PARAM = "token"
+	WS_TOKEN_TTL   = 30 * time.Second
+	WS_TOKEN_BYTES = 16
+)
+
+// ApiKey authenticates a client. The secret is either sent as is in the
+// X-Api-Key header or used to sign the request with HMAC-SHA256.
+type ApiKey struct {
+	Id     string
+	Secret string
+	Scopes []string
+}
+
+// HasScope reports whether the key grants the scope, admin grants every scope.
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == SCOPE_ADMIN {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore looks up api keys, returning nil for an unknown id.
+type KeyStore interface {
+	LoadApiKey(id string) (*ApiKey, error)
+}
+
+// KeyStores looks up a key in each store in turn.
+type KeyStores []KeyStore
+
+func (s KeyStores) LoadApiKey(id string) (*ApiKey, error) {
+	for _, store := range s {
+		key, err := store.LoadApiKey(id)
+		if err != nil || key != nil {
+			return key, err
+		}
+	}
+	return nil, nil
+}
+
+// FileKeyStore holds the keys of a json keyfile, a list of ApiKey objects.
+type FileKeyStore struct {
+	keys map[string]*ApiKey
+}
+
+func LoadKeyFile(path string) (*FileKeyStore, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadKeyFile, ioutil.ReadFile error: %s", err)
+	}
+	keys := make([]*ApiKey, 0)
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("LoadKeyFile, json.Unmarshal error: %s", err)
+	}
+	s := &FileKeyStore{keys: make(map[string]*ApiKey)}
+	for _, k := range keys {
+		if k.Id == "" || k.Secret == "" {
+			return nil, fmt.Errorf("LoadKeyFile, key without id or secret")
+		}
+		s.keys[k.Id] = k
+	}
+	return s, nil
+}
+
+func (s *FileKeyStore) LoadApiKey(id string) (*ApiKey, error) {
+	return s.keys[id], nil
+}
+
+// ApiKeyFromContext returns the key the Auth middleware authenticated.
+func ApiKeyFromContext(ctx context.Context) *ApiKey {
+	key, _ := ctx.Value(apiKeyKey).(*ApiKey)
+	return key
+}
+
+// nonceCache remembers the nonces of signed requests until their timestamp
+// leaves the skew window, so a captured request can not be replayed.
+type nonceCache struct {
+	lock   sync.Mutex
+	nonces map[string]time.Time // expiry by key id and nonce
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{nonces: make(map[string]time.Time)}
+}
+
+// use records the nonce of the key, it returns false if it was already used
+func (c *nonceCache) use(id, nonce string, ts, now time.Time) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := id + "|" + nonce
+	if expiry, ok := c.nonces[key]; ok && now.Before(expiry) {
+		return false
+	}
+	for k, expiry := range c.nonces {
+		if !now.Before(expiry) {
+			delete(c.nonces, k)
+		}
+	}
+	c.nonces[key] = ts.Add(MAX_SIGNATURE_SKEW)
+	return true
+}
+
+// tokenCache holds the tokens issued for websocket upgrades, a browser can
+// not set the api key headers on the upgrade request. A token is accepted
+// once and only until it expires.
+type tokenCache struct {
+	lock   sync.Mutex
+	tokens map[string]issuedToken
+}
+
+type issuedToken struct {
+	key    *ApiKey
+	expiry time.Time
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{tokens: make(map[string]issuedToken)}
+}
+
+// issue returns a new token of the key and its expiry
+func (c *tokenCache) issue(key *ApiKey, now time.Time) (string, time.Time, error) {
+	data := make([]byte, WS_TOKEN_BYTES)
+	if _, err := rand.Read(data); err != nil {
+		return "", time.Time{}, fmt.Errorf("issue, rand.Read error: %s", err)
+	}
+	token := hex.EncodeToString(data)
+	expiry := now.Add(WS_TOKEN_TTL)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for t, issued := range c.tokens {
+		if !now.Before(issued.expiry) {
+			delete(c.tokens, t)
+		}
+	}
+	c.tokens[token] = issuedToken{key: key, expiry: expiry}
+	return token, expiry, nil
+}
+
+// redeem returns the key of the token and forgets it, nil if the token is
+// unknown or expired
+func (c *tokenCache) redeem(token string, now time.Time) *ApiKey {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	issued, ok := c.tokens[token]
+	if !ok {
+		return nil
+	}
+	delete(c.tokens, token)
+	if !now.Before(issued.expiry) {
+		return nil
+	}
+	return issued.key
+}
+
+// Auth authenticates requests by api key or HMAC signature, paths in public
+// are served without credentials. A signed request is accepted once, its
+// nonce is remembered for as long as its timestamp is in the skew window.
+// Websocket upgrades may instead carry a token of tokens in the token query
+// parameter, tokens may be nil.
+func Auth(keyStore KeyStore, public map[string]bool, tokens *tokenCache) Middleware {
+	nonces := newNonceCache()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if public[r.URL.Path] || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var key *ApiKey
+			var err error
+			if token := r.URL.Query().Get(WS_TOKEN_PARAM); tokens != nil && token != "" && isUpgrade(r) {
+				if key = tokens.redeem(token, time.Now()); key == nil {
+					err = fmt.Errorf("invalid or expired %s", WS_TOKEN_PARAM)
+				}
+			} else {
+				key, err = authenticate(keyStore, nonces, r)
+			}
+			if err != nil {
+				requestLogger(r).With("remote", r.RemoteAddr).Warnf("unauthorized: %s", err)
+				writeError(w, http.StatusUnauthorized, UNAUTHORIZED)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyKey, key)))
+		})
+	}
+}
+
+func authenticate(keyStore KeyStore, nonces *nonceCache, r *http.Request) (*ApiKey, error) {
+	id := r.Header.Get(API_KEY_ID_HEADER)
+	if id == "" {
+		return nil, fmt.Errorf("missing %s", API_KEY_ID_HEADER)
+	}
+	key, err := keyStore.LoadApiKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("keyStore.LoadApiKey error: %s", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %s", id)
+	}
+
+	if secret := r.Header.Get(API_KEY_HEADER); secret != "" {
+		if !hmac.Equal([]byte(secret), []byte(key.Secret)) {
+			return nil, fmt.Errorf("invalid secret for key %s", id)
+		}
+		return key, nil
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(SIGNATURE_HEADER))
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("missing %s or %s", API_KEY_HEADER, SIGNATURE_HEADER)
+	}
+	timestamp := r.Header.Get(TIMESTAMP_HEADER)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", TIMESTAMP_HEADER)
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(ts, 0)); skew > MAX_SIGNATURE_SKEW || skew < -MAX_SIGNATURE_SKEW {
+		return nil, fmt.Errorf("%s out of range", TIMESTAMP_HEADER)
+	}
+	nonce := r.Header.Get(NONCE_HEADER)
+	if nonce == "" || len(nonce) > MAX_NONCE_LENGTH {
+		return nil, fmt.Errorf("invalid %s", NONCE_HEADER)
+	}
+	var body []byte
+	if r.Body != nil {
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read body error: %s", err)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if !hmac.Equal(signature, Sign(key.Secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body)) {
+		return nil, fmt.Errorf("invalid signature for key %s", id)
+	}
+	if !nonces.use(id, nonce, time.Unix(ts, 0), now) {
+		return nil, fmt.Errorf("%s replayed for key %s", NONCE_HEADER, id)
+	}
+	return key, nil
+}
+
+// Sign returns the HMAC-SHA256 of method, request uri, unix timestamp, nonce
+// and the sha256 of the body, each separated by a newline.
+func Sign(secret, method, uri, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return mac.Sum(nil)
+}
+
+// authorize checks the key of the request grants the scope. When
+// authentication is disabled every request is authorized but admin ones.
+func (this *restServer) authorize(ctx context.Context, scope string) uint32 {
+	if this.config.KeyStore == nil {
+		if scope == SCOPE_ADMIN {
+			return FORBIDDEN
+		}
+		return SUCCESS
+	}
+	key := ApiKeyFromContext(ctx)
+	if key == nil {
+		return UNAUTHORIZED
+	}
+	if scope == "" {
+		scope = SCOPE_READ
+	}
+	if !key.HasScope(scope) {
+		return FORBIDDEN
+	}
+	return SUCCESS
+}
+
+// requireScope wraps a handler so it is only served to keys granting scope
+func (this *restServer) requireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if errCode := this.authorize(r.Context(), scope); errCode != SUCCESS {
+			status := http.StatusForbidden
+			if errCode == UNAUTHORIZED {
+				status = http.StatusUnauthorized
+			}
+			writeError(w, status, errCode)
+			return
+		}
+		handler(w, r)
+	}
+}
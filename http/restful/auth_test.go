@@ -0,0 +1,226 @@
+package restful
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKeys = &FileKeyStore{keys: map[string]*ApiKey{
+	"reader": {Id: "reader", Secret: "reader-secret", Scopes: []string{SCOPE_READ}},
+	"admin":  {Id: "admin", Secret: "admin-secret", Scopes: []string{SCOPE_ADMIN}},
+}}
+
+// signedRequest signs a request of the key with the timestamp and nonce
+func signedRequest(id, secret, body string, ts time.Time, nonce string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/getrewards?x=1", strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(API_KEY_ID_HEADER, id)
+	r.Header.Set(TIMESTAMP_HEADER, timestamp)
+	r.Header.Set(NONCE_HEADER, nonce)
+	r.Header.Set(SIGNATURE_HEADER, hex.EncodeToString(Sign(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, []byte(body))))
+	return r
+}
+
+// serveAuth serves r through Auth, the handler echoes the body it reads
+func serveAuth(auth Middleware, r *http.Request) (int, string) {
+	h := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestAuthSignature(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{"valid", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{"a":1}`, now, "n1")
+		}, http.StatusOK},
+		{"inside skew", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{}`, now.Add(-MAX_SIGNATURE_SKEW+time.Minute), "n2")
+		}, http.StatusOK},
+		{"future inside skew", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{}`, now.Add(MAX_SIGNATURE_SKEW-time.Minute), "n3")
+		}, http.StatusOK},
+		{"too old", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{}`, now.Add(-MAX_SIGNATURE_SKEW-time.Minute), "n4")
+		}, http.StatusUnauthorized},
+		{"too far ahead", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{}`, now.Add(MAX_SIGNATURE_SKEW+time.Minute), "n5")
+		}, http.StatusUnauthorized},
+		{"body changed", func() *http.Request {
+			r := signedRequest("reader", "reader-secret", `{"a":1}`, now, "n6")
+			r.Body = ioutil.NopCloser(strings.NewReader(`{"a":2}`))
+			return r
+		}, http.StatusUnauthorized},
+		{"uri changed", func() *http.Request {
+			r := signedRequest("reader", "reader-secret", `{}`, now, "n7")
+			r.URL.RawQuery = "x=2"
+			return r
+		}, http.StatusUnauthorized},
+		{"nonce changed", func() *http.Request {
+			r := signedRequest("reader", "reader-secret", `{}`, now, "n8")
+			r.Header.Set(NONCE_HEADER, "n9")
+			return r
+		}, http.StatusUnauthorized},
+		{"missing nonce", func() *http.Request {
+			return signedRequest("reader", "reader-secret", `{}`, now, "")
+		}, http.StatusUnauthorized},
+		{"wrong secret", func() *http.Request {
+			return signedRequest("reader", "admin-secret", `{}`, now, "n10")
+		}, http.StatusUnauthorized},
+		{"unknown key", func() *http.Request {
+			return signedRequest("nobody", "reader-secret", `{}`, now, "n11")
+		}, http.StatusUnauthorized},
+	}
+	auth := Auth(testKeys, nil, nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status, _ := serveAuth(auth, test.req()); status != test.status {
+				t.Errorf("status %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestAuthBodyReadable(t *testing.T) {
+	status, body := serveAuth(Auth(testKeys, nil, nil), signedRequest("reader", "reader-secret", `{"a":1}`, time.Now(), "n"))
+	if status != http.StatusOK || body != `{"a":1}` {
+		t.Errorf("got %d %q, want the body after the signature check", status, body)
+	}
+}
+
+func TestAuthReplay(t *testing.T) {
+	auth := Auth(testKeys, nil, nil)
+	now := time.Now()
+	if status, _ := serveAuth(auth, signedRequest("reader", "reader-secret", `{}`, now, "once")); status != http.StatusOK {
+		t.Fatalf("first request status %d", status)
+	}
+	if status, _ := serveAuth(auth, signedRequest("reader", "reader-secret", `{}`, now, "once")); status != http.StatusUnauthorized {
+		t.Errorf("replayed request status %d, want %d", status, http.StatusUnauthorized)
+	}
+	// nonces are scoped by key
+	if status, _ := serveAuth(auth, signedRequest("admin", "admin-secret", `{}`, now, "once")); status != http.StatusOK {
+		t.Errorf("nonce of another key status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestNonceCacheExpiry(t *testing.T) {
+	c := newNonceCache()
+	ts := time.Unix(1000, 0)
+	if !c.use("k", "n", ts, ts) {
+		t.Fatal("first use rejected")
+	}
+	if c.use("k", "n", ts, ts.Add(MAX_SIGNATURE_SKEW-time.Second)) {
+		t.Error("nonce reused inside the skew window")
+	}
+	if !c.use("k", "other", ts, ts.Add(MAX_SIGNATURE_SKEW)) {
+		t.Error("new nonce rejected")
+	}
+	if _, ok := c.nonces["k|n"]; ok {
+		t.Error("expired nonce not pruned")
+	}
+}
+
+func TestAuthScope(t *testing.T) {
+	srv := &restServer{config: &Config{KeyStore: testKeys}}
+	h := Auth(testKeys, nil, nil)(srv.requireScope(SCOPE_ADMIN, func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+		status int
+	}{
+		{"admin key", "admin", "admin-secret", http.StatusOK},
+		{"read key", "reader", "reader-secret", http.StatusForbidden},
+		{"no key", "", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/api/v2/loglevels", nil)
+			if test.id != "" {
+				r.Header.Set(API_KEY_ID_HEADER, test.id)
+				r.Header.Set(API_KEY_HEADER, test.secret)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Errorf("status %d, want %d", w.Code, test.status)
+			}
+		})
+	}
+}
+
+func TestAuthScopeDisabled(t *testing.T) {
+	srv := &restServer{config: &Config{}}
+	if errCode := srv.authorize(context.Background(), SCOPE_READ); errCode != SUCCESS {
+		t.Errorf("read without authentication error %d, want %d", errCode, SUCCESS)
+	}
+	if errCode := srv.authorize(context.Background(), SCOPE_ADMIN); errCode != FORBIDDEN {
+		t.Errorf("admin without authentication error %d, want %d", errCode, FORBIDDEN)
+	}
+}
+
+// upgradeRequest is a websocket upgrade carrying token in its query
+func upgradeRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/ws?"+WS_TOKEN_PARAM+"="+token, nil)
+	r.Header.Set("Upgrade", "websocket")
+	return r
+}
+
+func TestAuthWsToken(t *testing.T) {
+	tokens := newTokenCache()
+	auth := Auth(testKeys, nil, tokens)
+	token, _, err := tokens.issue(testKeys.keys["reader"], time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/ws?"+WS_TOKEN_PARAM+"="+token, nil)
+	if status, _ := serveAuth(auth, r); status != http.StatusUnauthorized {
+		t.Errorf("token without upgrade status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := serveAuth(auth, upgradeRequest(token)); status != http.StatusOK {
+		t.Fatalf("upgrade status %d, want %d", status, http.StatusOK)
+	}
+	if status, _ := serveAuth(auth, upgradeRequest(token)); status != http.StatusUnauthorized {
+		t.Errorf("reused token status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := serveAuth(auth, upgradeRequest("unknown")); status != http.StatusUnauthorized {
+		t.Errorf("unknown token status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestTokenCacheExpiry(t *testing.T) {
+	c := newTokenCache()
+	now := time.Unix(1000, 0)
+	token, expiry, err := c.issue(testKeys.keys["reader"], now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(now.Add(WS_TOKEN_TTL)) {
+		t.Errorf("expiry %s, want %s", expiry, now.Add(WS_TOKEN_TTL))
+	}
+	if key := c.redeem(token, expiry); key != nil {
+		t.Error("expired token redeemed")
+	}
+	expired, _, _ := c.issue(testKeys.keys["reader"], now)
+	if _, _, err = c.issue(testKeys.keys["reader"], expiry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.tokens[expired]; ok {
+		t.Error("expired token not pruned")
+	}
+}
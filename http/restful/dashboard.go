@@ -0,0 +1,25 @@
+package restful
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/polynetwork/distribute-check/http/common"
+)
+
+// dashboardPage is a status page built on the json apis, api keys are
+// entered in the page when authentication is enabled.
+//
+//go:embed dashboard/index.html
+var dashboardPage []byte
+
+// init dashboard Handler
+func (this *restServer) initDashboardHandler() {
+	this.router.Get(common.DASHBOARD, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html;charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write(dashboardPage)
+	})
+}
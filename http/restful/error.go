@@ -5,17 +5,31 @@ const (
 	SUCCESS uint32 = 0
 	FAILED  uint32 = 1
 
-	INVALID_METHOD     uint32 = 42001
-	INVALID_PARAMS     uint32 = 42002
-	ILLEGAL_DATAFORMAT uint32 = 42003
-	INTERNAL_ERROR     uint32 = 42004
+	INVALID_METHOD      uint32 = 42001
+	INVALID_PARAMS      uint32 = 42002
+	ILLEGAL_DATAFORMAT  uint32 = 42003
+	INTERNAL_ERROR      uint32 = 42004
+	REQUEST_TOO_LARGE   uint32 = 42005
+	REQUEST_TIMEOUT     uint32 = 42006
+	UNAUTHORIZED        uint32 = 42007
+	FORBIDDEN           uint32 = 42008
+	RATE_LIMITED        uint32 = 42009
+	QUERY_TOO_EXPENSIVE uint32 = 42010
+	INVALID_REQUEST     uint32 = 42011
 )
 
 var ErrMap = map[uint32]string{
-	SUCCESS:            "SUCCESS",
-	FAILED:             "FAILED",
-	INVALID_METHOD:     "INVALID METHOD",
-	INVALID_PARAMS:     "INVALID PARAMS",
-	ILLEGAL_DATAFORMAT: "ILLEGAL DATAFORMAT",
-	INTERNAL_ERROR:     "INTERNAL_ERROR",
+	SUCCESS:             "SUCCESS",
+	FAILED:              "FAILED",
+	INVALID_METHOD:      "INVALID METHOD",
+	INVALID_PARAMS:      "INVALID PARAMS",
+	ILLEGAL_DATAFORMAT:  "ILLEGAL DATAFORMAT",
+	INTERNAL_ERROR:      "INTERNAL_ERROR",
+	REQUEST_TOO_LARGE:   "REQUEST TOO LARGE",
+	REQUEST_TIMEOUT:     "REQUEST TIMEOUT",
+	UNAUTHORIZED:        "UNAUTHORIZED",
+	FORBIDDEN:           "FORBIDDEN",
+	RATE_LIMITED:        "RATE LIMITED",
+	QUERY_TOO_EXPENSIVE: "QUERY TOO EXPENSIVE",
+	INVALID_REQUEST:     "INVALID REQUEST",
 }
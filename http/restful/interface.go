@@ -1,6 +1,29 @@
 package restful
 
+import (
+	"context"
+
+	"github.com/polynetwork/distribute-check/http/common"
+)
+
 type Web interface {
-	GetRewards(map[string]interface{}) map[string]interface{}
-	GetGasFee(map[string]interface{}) map[string]interface{}
+	GetRewards(context.Context, *common.GetRewardsRequest) (*common.GetRewardsResponse, error)
+	GetGasFee(context.Context, *common.GetGasFeeRequest) (*common.GetGasFeeResponse, error)
+	GetRewardBreakdown(context.Context, *common.GetRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error)
+	ExplainRewards(context.Context, *common.ExplainRewardsRequest) (*common.ExplainRewardsResponse, error)
+
+	AddressRewards(context.Context, *common.AddressRewardsRequest) (*common.AddressRewardsResponse, error)
+	AddressGasFee(context.Context, *common.AddressGasFeeRequest) (*common.AddressGasFeeResponse, error)
+	AddressRewardBreakdown(context.Context, *common.AddressRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error)
+	AddressRewardsExplain(context.Context, *common.AddressRewardsExplainRequest) (*common.ExplainRewardsResponse, error)
+	CacheStats(context.Context, *common.CacheStatsRequest) (*common.CacheStatsResponse, error)
+	Status(context.Context, *common.StatusRequest) (*common.StatusResponse, error)
+	Validators(context.Context, *common.ValidatorsRequest) (*common.ValidatorsResponse, error)
+	Blocks(context.Context, *common.BlocksRequest) (*common.BlocksResponse, error)
+	BlockDust(context.Context, *common.BlockDustRequest) (*common.BlockDustResponse, error)
+	Mismatches(context.Context, *common.MismatchesRequest) (*common.MismatchesResponse, error)
+
+	StreamRewards(context.Context, *common.GetRewardsRequest, RowWriter) error
+	StreamGasFee(context.Context, *common.GetGasFeeRequest, RowWriter) error
+	StreamRewardBreakdown(context.Context, *common.GetRewardBreakdownRequest, RowWriter) error
 }
@@ -0,0 +1,216 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+const JSONRPC_VERSION = "2.0"
+
+// JSON-RPC 2.0 error codes
+const (
+	RPC_PARSE_ERROR      = -32700
+	RPC_INVALID_REQUEST  = -32600
+	RPC_METHOD_NOT_FOUND = -32601
+	RPC_INVALID_PARAMS   = -32602
+	RPC_INTERNAL_ERROR   = -32603
+	RPC_SERVER_ERROR     = -32000
+)
+
+// RpcErrMap maps the error codes of ErrMap to JSON-RPC error codes, codes
+// not listed here are reported as RPC_SERVER_ERROR.
+var RpcErrMap = map[uint32]int{
+	FAILED:             RPC_SERVER_ERROR,
+	INVALID_METHOD:     RPC_METHOD_NOT_FOUND,
+	INVALID_PARAMS:     RPC_INVALID_PARAMS,
+	ILLEGAL_DATAFORMAT: RPC_PARSE_ERROR,
+	INTERNAL_ERROR:     RPC_INTERNAL_ERROR,
+	INVALID_REQUEST:    RPC_INVALID_REQUEST,
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON emits result on success even if it is nil, a response holds
+// exactly one of result and error
+func (resp *rpcResponse) MarshalJSON() ([]byte, error) {
+	if resp.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *rpcError       `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{resp.JSONRPC, resp.Error, resp.ID})
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{resp.JSONRPC, resp.Result, resp.ID})
+}
+
+var (
+	nullID           = json.RawMessage("null")
+	errTooManyParams = errors.New("params array must hold a single object")
+)
+
+func newRpcError(id json.RawMessage, errCode uint32, desc interface{}, details ...interface{}) *rpcResponse {
+	code, ok := RpcErrMap[errCode]
+	if !ok {
+		code = RPC_SERVER_ERROR
+	}
+	if id == nil {
+		id = nullID
+	}
+	data := map[string]interface{}{"error": errCode, "desc": desc}
+	if len(details) > 0 && details[0] != nil && details[0] != "" {
+		data["details"] = details[0]
+	}
+	return &rpcResponse{
+		JSONRPC: JSONRPC_VERSION,
+		Error: &rpcError{
+			Code:    code,
+			Message: ErrMap[errCode],
+			Data:    data,
+		},
+		ID: id,
+	}
+}
+
+// serve json rpc, a batch is answered with an array of responses
+func (this *restServer) handleRpc(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		requestLogger(r).Errorf("read rpc body error: %s", err)
+		this.rpcResponse(w, newRpcError(nil, readErrorCode(err), err.Error()))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			requestLogger(r).Errorf("unmarshal rpc batch error: %s", err)
+			this.rpcResponse(w, newRpcError(nil, ILLEGAL_DATAFORMAT, err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			this.rpcResponse(w, newRpcError(nil, INVALID_REQUEST, "empty batch"))
+			return
+		}
+		resps := make([]*rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp := this.callRpc(r, raw); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			this.write(w, []byte{})
+			return
+		}
+		this.rpcResponse(w, resps)
+		return
+	}
+
+	resp := this.callRpc(r, body)
+	if resp == nil {
+		this.write(w, []byte{})
+		return
+	}
+	this.rpcResponse(w, resp)
+}
+
+// call a single json rpc request, returns nil for notifications. Invalid
+// json is a parse error, valid json which is not a request object, e.g. an
+// element of a batch, is an invalid request.
+func (this *restServer) callRpc(r *http.Request, raw json.RawMessage) *rpcResponse {
+	if !json.Valid(raw) {
+		requestLogger(r).Errorf("unmarshal rpc request error: invalid json")
+		return newRpcError(nil, ILLEGAL_DATAFORMAT, "invalid json")
+	}
+	req := new(rpcRequest)
+	if err := json.Unmarshal(raw, req); err != nil {
+		return newRpcError(nil, INVALID_REQUEST, err.Error())
+	}
+	if req.JSONRPC != JSONRPC_VERSION || req.Method == "" {
+		return newRpcError(req.ID, INVALID_REQUEST, "invalid request")
+	}
+
+	var resp *rpcResponse
+	if h, ok := this.methodMap[req.Method]; ok {
+		params, raw, err := parseRpcParams(req.Params)
+		if err != nil {
+			resp = newRpcError(req.ID, INVALID_PARAMS, err.Error())
+		} else {
+			result := this.invoke(r, h, params, raw)
+			if result.Error != SUCCESS {
+				resp = newRpcError(req.ID, result.Error, ErrMap[result.Error], result.Result)
+			} else {
+				resp = &rpcResponse{JSONRPC: JSONRPC_VERSION, Result: result.Result, ID: req.ID}
+			}
+		}
+	} else {
+		resp = newRpcError(req.ID, INVALID_METHOD, req.Method)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+// params are either a single object or an array holding one object, they
+// are returned decoded and as the raw object
+func parseRpcParams(raw json.RawMessage) (map[string]interface{}, json.RawMessage, error) {
+	params := make(map[string]interface{})
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, nullID) {
+		return params, json.RawMessage("{}"), nil
+	}
+	if raw[0] == '[' {
+		var list []json.RawMessage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, nil, err
+		}
+		if len(list) > 1 {
+			return nil, nil, errTooManyParams
+		}
+		if len(list) == 0 {
+			return params, json.RawMessage("{}"), nil
+		}
+		raw = list[0]
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, nil, err
+	}
+	return params, raw, nil
+}
+
+func (this *restServer) rpcResponse(w http.ResponseWriter, resp interface{}) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		logger.Errorf("HTTP Handle - json.Marshal: %v", err)
+		return
+	}
+	this.write(w, data)
+}
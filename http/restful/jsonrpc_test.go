@@ -0,0 +1,86 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveRpc posts body to the json rpc handler of a server without methods
+func serveRpc(body string) string {
+	srv := &restServer{methodMap: make(map[string]*Action)}
+	w := httptest.NewRecorder()
+	srv.handleRpc(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	return w.Body.String()
+}
+
+// rpcCodes returns the error codes of a response or of each response of a
+// batch, 0 for a result
+func rpcCodes(t *testing.T, body string) []int {
+	var resps []struct {
+		Error *rpcError
+	}
+	if strings.HasPrefix(body, "[") {
+		if err := json.Unmarshal([]byte(body), &resps); err != nil {
+			t.Fatalf("unmarshal %s: %s", body, err)
+		}
+	} else {
+		resps = append(resps, struct{ Error *rpcError }{})
+		if err := json.Unmarshal([]byte(body), &resps[0]); err != nil {
+			t.Fatalf("unmarshal %s: %s", body, err)
+		}
+	}
+	codes := make([]int, 0, len(resps))
+	for _, resp := range resps {
+		code := 0
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func TestRpcErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		codes []int
+	}{
+		{"invalid json", `{"jsonrpc":"2.0",`, []int{RPC_PARSE_ERROR}},
+		{"invalid batch json", `[{"jsonrpc":"2.0"},`, []int{RPC_PARSE_ERROR}},
+		{"empty batch", `[]`, []int{RPC_INVALID_REQUEST}},
+		{"not an object", `1`, []int{RPC_INVALID_REQUEST}},
+		{"batch of non objects", `[1, "a", null]`, []int{RPC_INVALID_REQUEST, RPC_INVALID_REQUEST, RPC_INVALID_REQUEST}},
+		{"missing version", `{"method":"a","id":1}`, []int{RPC_INVALID_REQUEST}},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`, []int{RPC_INVALID_REQUEST}},
+		{"wrong field type", `{"jsonrpc":"2.0","method":1,"id":1}`, []int{RPC_INVALID_REQUEST}},
+		{"unknown method", `{"jsonrpc":"2.0","method":"a","id":1}`, []int{RPC_METHOD_NOT_FOUND}},
+		{"mixed batch", `[{"jsonrpc":"2.0","method":"a","id":1}, 2]`, []int{RPC_METHOD_NOT_FOUND, RPC_INVALID_REQUEST}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := serveRpc(test.body)
+			codes := rpcCodes(t, body)
+			if len(codes) != len(test.codes) {
+				t.Fatalf("%d responses in %s, want %d", len(codes), body, len(test.codes))
+			}
+			for i, code := range codes {
+				if code != test.codes[i] {
+					t.Errorf("response %d code %d, want %d", i, code, test.codes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRpcNotifications(t *testing.T) {
+	if body := serveRpc(`{"jsonrpc":"2.0","method":"a"}`); body != "" {
+		t.Errorf("notification answered with %s", body)
+	}
+	if body := serveRpc(`[{"jsonrpc":"2.0","method":"a"},{"jsonrpc":"2.0","method":"b"}]`); body != "" {
+		t.Errorf("batch of notifications answered with %s", body)
+	}
+}
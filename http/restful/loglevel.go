@@ -0,0 +1,58 @@
+package restful
+
+import (
+	"context"
+
+	"github.com/polynetwork/distribute-check/http/common"
+	"github.com/polynetwork/distribute-check/log"
+)
+
+// getLogLevels answers the global level and the levels set per component
+func getLogLevels(ctx context.Context, req *common.GetLogLevelsRequest) (*common.LogLevelsResponse, error) {
+	return &common.LogLevelsResponse{
+		Level:      log.LevelString(log.Level()),
+		Components: log.ComponentLevels(),
+	}, nil
+}
+
+// setLogLevels changes the levels at runtime, nothing is changed if any of
+// the levels is invalid.
+func setLogLevels(ctx context.Context, req *common.SetLogLevelsRequest) (*common.LogLevelsResponse, error) {
+	global := -1
+	if req.Level != "" {
+		level, err := log.ParseLevel(req.Level)
+		if err != nil {
+			return nil, NewError(INVALID_PARAMS, err.Error())
+		}
+		global = level
+	}
+	levels := make(map[string]int, len(req.Components))
+	for component, name := range req.Components {
+		if err := log.CheckComponent(component); err != nil {
+			return nil, NewError(INVALID_PARAMS, err.Error())
+		}
+		if name == "" {
+			continue
+		}
+		level, err := log.ParseLevel(name)
+		if err != nil {
+			return nil, NewError(INVALID_PARAMS, component+": "+err.Error())
+		}
+		levels[component] = level
+	}
+
+	if global >= 0 {
+		if err := log.Log().SetDebugLevel(global); err != nil {
+			return nil, err
+		}
+	}
+	for component, name := range req.Components {
+		if name == "" {
+			log.ResetComponentLevel(component)
+		} else if err := log.SetComponentLevel(component, levels[component]); err != nil {
+			return nil, err
+		}
+	}
+	logger.With("request_id", RequestId(ctx), "level", req.Level, "components", req.Components).Warn("log levels changed")
+	return getLogLevels(ctx, nil)
+}
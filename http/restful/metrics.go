@@ -0,0 +1,56 @@
+package restful
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/polynetwork/distribute-check/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_http_requests_total",
+		Help: "HTTP requests by action and status.",
+	}, []string{"action", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "distribute_check_http_request_duration_seconds",
+		Help: "Latency of HTTP requests by action.",
+	}, []string{"action"})
+)
+
+// instrument counts the requests of an action and their latency, and traces
+// them as children of the span of the traceparent header if any
+func instrument(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.StartKind(ctx, trace.SpanKindServer, "HTTP "+action,
+			semconv.HTTPMethodKey.String(r.Method), semconv.HTTPTargetKey.String(r.URL.Path), attribute.String("request_id", RequestId(ctx)))
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			status := sw.status
+			if err := recover(); err != nil {
+				status = http.StatusInternalServerError
+				defer panic(err)
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+			httpRequests.WithLabelValues(action, strconv.Itoa(status)).Inc()
+			httpDuration.WithLabelValues(action).Observe(time.Since(start).Seconds())
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			if status >= http.StatusInternalServerError {
+				tracing.SetError(span, fmt.Errorf("status %d", status))
+			}
+			span.End()
+		}()
+		next(sw, r.WithContext(ctx))
+	}
+}
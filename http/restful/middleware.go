@@ -0,0 +1,222 @@
+package restful
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/distribute-check/log"
+)
+
+const (
+	REQUEST_ID_HEADER = "X-Request-Id"
+
+	DEFAULT_REQUEST_TIMEOUT = 60 * time.Second
+	DEFAULT_MAX_BODY_SIZE   = 1 << 20
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// Middleware wraps a handler with a cross-cutting concern.
+type Middleware func(http.Handler) http.Handler
+
+// RequestId returns the id the RequestIds middleware assigned to the request.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey).(string)
+	return id
+}
+
+// requestLogger logs with the request id, method and path of r
+func requestLogger(r *http.Request) *log.Entry {
+	return logger.With("request_id", RequestId(r.Context()), "method", r.Method, "path", r.URL.Path)
+}
+
+// RequestIds takes the request id from the X-Request-Id header or generates
+// one, and echoes it in the response.
+func RequestIds() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(REQUEST_ID_HEADER)
+			if id == "" || len(id) > 64 {
+				id = newRequestId()
+			}
+			w.Header().Set(REQUEST_ID_HEADER, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey, id)))
+		})
+	}
+}
+
+func newRequestId() string {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// AccessLog logs every request with its status, size and latency.
+func AccessLog() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			requestLogger(r).With("status", sw.status, "bytes", sw.size, "latency", time.Since(start), "remote", r.RemoteAddr).
+				Info("request")
+		})
+	}
+}
+
+// Recovery turns a panic in a handler into an INTERNAL_ERROR response.
+func Recovery() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					requestLogger(r).With("stack", string(debug.Stack())).Errorf("panic: %v", err)
+					writeError(w, http.StatusInternalServerError, INTERNAL_ERROR)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout bounds the time a request may take. Websocket upgrades are not
+// bounded as the connection outlives the request, nor are csv and ndjson
+// streams of the routes streaming reports, which the timeout handler would
+// buffer.
+func Timeout(timeout time.Duration, streaming func(*http.Request) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		data, _ := json.Marshal(PackResponseWithDesc(REQUEST_TIMEOUT))
+		th := http.TimeoutHandler(next, timeout, string(data))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isUpgrade(r) ||
+				(streaming != nil && streaming(r) && outputFormat(r) != FORMAT_JSON) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			th.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isUpgrade reports whether r upgrades to a websocket
+func isUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// BodyLimit rejects request bodies larger than limit bytes.
+func BodyLimit(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeError(w, http.StatusRequestEntityTooLarge, REQUEST_TOO_LARGE)
+				return
+			}
+			if r.Body != nil {
+				r.Body = &limitedBody{ReadCloser: r.Body, remaining: limit}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody fails with errBodyTooLarge once more than the limit is read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// readErrorCode maps an error reading the request body to an error code
+func readErrorCode(err error) uint32 {
+	if errors.Is(err, errBodyTooLarge) {
+		return REQUEST_TOO_LARGE
+	}
+	return ILLEGAL_DATAFORMAT
+}
+
+func writeError(w http.ResponseWriter, status int, errCode uint32) {
+	data, _ := json.Marshal(PackResponseWithDesc(errCode))
+	w.Header().Set("content-type", "application/json;charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// statusWriter records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,64 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/distribute-check/http/common"
+)
+
+const DEFAULT_PROBE_TIMEOUT = 3 * time.Second
+
+// Probe is a named check of a health or readiness endpoint, it fails by
+// returning an error.
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// init health and readiness Handler, readiness includes the health checks
+func (this *restServer) initProbeHandler() {
+	this.router.Get(common.HEALTHZ, probeHandler(this.config.Liveness))
+	readiness := append(append([]Probe(nil), this.config.Liveness...), this.config.Readiness...)
+	this.router.Get(common.READYZ, probeHandler(readiness))
+}
+
+// probeHandler runs the probes concurrently and answers 503 if any fails
+func probeHandler(probes []Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), DEFAULT_PROBE_TIMEOUT)
+		defer cancel()
+		resp := &common.ProbeResponse{Status: common.PROBE_OK, Checks: make([]common.ProbeCheck, len(probes))}
+		var wg sync.WaitGroup
+		for i, probe := range probes {
+			wg.Add(1)
+			go func(i int, probe Probe) {
+				defer wg.Done()
+				start := time.Now()
+				check := common.ProbeCheck{Name: probe.Name, Status: common.PROBE_OK}
+				if err := probe.Check(ctx); err != nil {
+					check.Status = common.PROBE_FAIL
+					check.Error = err.Error()
+				}
+				check.Duration = time.Since(start).String()
+				resp.Checks[i] = check
+			}(i, probe)
+		}
+		wg.Wait()
+		status := http.StatusOK
+		for _, check := range resp.Checks {
+			if check.Status != common.PROBE_OK {
+				resp.Status = common.PROBE_FAIL
+				status = http.StatusServiceUnavailable
+			}
+		}
+		data, _ := json.Marshal(resp)
+		w.Header().Set("content-type", "application/json;charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+}
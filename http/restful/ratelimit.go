@@ -0,0 +1,238 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// a query costs one token per address per HEIGHT_COST_UNIT blocks scanned
+	HEIGHT_COST_UNIT = 10000
+
+	MAX_IDLE_BUCKETS  = 10000
+	BUCKET_IDLE_RESET = 10 * time.Minute
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst, a
+// Rate of 0 disables it. Queries costing more than MaxCost are rejected,
+// MaxCost defaults to Burst when the bucket is enabled. A query waits at most
+// MaxWaitMs milliseconds for its tokens before it is rejected.
+type Limit struct {
+	Rate      float64
+	Burst     float64
+	MaxCost   float64
+	MaxWaitMs uint64
+}
+
+// RateLimitConfig holds the default limit and the limits of endpoints by
+// action name.
+type RateLimitConfig struct {
+	Default   Limit
+	Endpoints map[string]Limit
+}
+
+func LoadRateLimitFile(path string) (*RateLimitConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadRateLimitFile, ioutil.ReadFile error: %s", err)
+	}
+	config := new(RateLimitConfig)
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("LoadRateLimitFile, json.Unmarshal error: %s", err)
+	}
+	return config, nil
+}
+
+func (c *RateLimitConfig) limit(action string) Limit {
+	if l, ok := c.Endpoints[action]; ok {
+		return l
+	}
+	return c.Default
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	config  *RateLimitConfig
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	after   func(time.Duration) <-chan time.Time
+}
+
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	return &rateLimiter{config: config, buckets: make(map[string]*bucket), now: time.Now, after: time.After}
+}
+
+// take reserves cost tokens of the bucket of the client for the action,
+// waiting if the bucket refills within MaxWait. The tokens are given back if
+// ctx is done while waiting.
+func (l *rateLimiter) take(ctx context.Context, action, client string, cost float64) uint32 {
+	limit := l.config.limit(action)
+	maxCost := limit.MaxCost
+	if limit.Rate > 0 && (maxCost <= 0 || maxCost > limit.Burst) {
+		maxCost = limit.Burst
+	}
+	if maxCost > 0 && cost > maxCost {
+		return QUERY_TOO_EXPENSIVE
+	}
+	if limit.Rate <= 0 {
+		return SUCCESS
+	}
+
+	l.lock.Lock()
+	now := l.now()
+	l.cleanup(now)
+	key := action + "|" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.Burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	wait := time.Duration(0)
+	if b.tokens < cost {
+		wait = time.Duration((cost - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	if wait > time.Duration(limit.MaxWaitMs)*time.Millisecond {
+		l.lock.Unlock()
+		return RATE_LIMITED
+	}
+	b.tokens -= cost
+	l.lock.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-l.after(wait):
+		case <-ctx.Done():
+			l.lock.Lock()
+			b.tokens = math.Min(limit.Burst, b.tokens+cost)
+			l.lock.Unlock()
+			return REQUEST_TIMEOUT
+		}
+	}
+	return SUCCESS
+}
+
+// cleanup drops buckets idle long enough to be full again
+func (l *rateLimiter) cleanup(now time.Time) {
+	if len(l.buckets) < MAX_IDLE_BUCKETS {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > BUCKET_IDLE_RESET {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// queryCost estimates the cost of a query from its addresses and height range
+func queryCost(req map[string]interface{}) float64 {
+	addresses := 1.0
+	if v, ok := lookupParam(req, "Addresses"); ok {
+		if list, ok := v.([]interface{}); ok && len(list) > 1 {
+			addresses = float64(len(list))
+		}
+	}
+	heights := 1.0
+	if end, ok := numberParam(req, "EndHeight"); ok {
+		start, _ := numberParam(req, "StartHeight")
+		if end > start {
+			heights = end - start
+		}
+	}
+	return addresses * (1 + heights/HEIGHT_COST_UNIT)
+}
+
+// lookupParam finds a param matching the name case-insensitively, like
+// encoding/json matches struct fields
+func lookupParam(req map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := req[name]; ok {
+		return v, true
+	}
+	for k, v := range req {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func numberParam(req map[string]interface{}, name string) (float64, bool) {
+	v, ok := lookupParam(req, name)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// clientId identifies the client by its api key, or by its ip
+func clientId(r *http.Request) string {
+	if key := ApiKeyFromContext(r.Context()); key != nil {
+		return "key:" + key.Id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimit charges the cost of the request to its client
+func (this *restServer) rateLimit(r *http.Request, action string, req map[string]interface{}) uint32 {
+	if this.limiter == nil {
+		return SUCCESS
+	}
+	return this.limiter.take(r.Context(), action, clientId(r), queryCost(req))
+}
+
+// limitTyped wraps a typed handler with the rate limit of its action, the
+// cost is estimated from the path and query parameters
+func (this *restServer) limitTyped(action string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := make(map[string]interface{})
+		for k, v := range r.URL.Query() {
+			req[k] = v[0]
+		}
+		if errCode := this.rateLimit(r, action, req); errCode != SUCCESS {
+			writeError(w, limitStatus(errCode), errCode)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// limitStatus is the http status answering an error of the rate limit, a
+// query too expensive to ever be served is not retried later
+func limitStatus(errCode uint32) int {
+	switch errCode {
+	case QUERY_TOO_EXPENSIVE:
+		return http.StatusUnprocessableEntity
+	case REQUEST_TIMEOUT:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusTooManyRequests
+}
@@ -0,0 +1,172 @@
+package restful
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock drives a rateLimiter, waits return at once and are recorded
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+	block bool // waits never return
+}
+
+func newTestLimiter(limit Limit) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := newRateLimiter(&RateLimitConfig{Default: limit})
+	l.now = func() time.Time { return clock.now }
+	l.after = func(d time.Duration) <-chan time.Time {
+		clock.waits = append(clock.waits, d)
+		ch := make(chan time.Time, 1)
+		if !clock.block {
+			ch <- clock.now.Add(d)
+		}
+		return ch
+	}
+	return l, clock
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 2, Burst: 4})
+	for i := 0; i < 4; i++ {
+		if errCode := l.take(context.Background(), "a", "c", 1); errCode != SUCCESS {
+			t.Fatalf("take %d of the burst: %d", i, errCode)
+		}
+	}
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != RATE_LIMITED {
+		t.Fatalf("take past the burst: %d, want RATE_LIMITED", errCode)
+	}
+	// other clients and actions have their own buckets
+	if errCode := l.take(context.Background(), "a", "other", 1); errCode != SUCCESS {
+		t.Errorf("take of another client: %d", errCode)
+	}
+	if errCode := l.take(context.Background(), "b", "c", 1); errCode != SUCCESS {
+		t.Errorf("take of another action: %d", errCode)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if errCode := l.take(context.Background(), "a", "c", 1); errCode != SUCCESS {
+			t.Fatalf("take %d after refilling 2 tokens: %d", i, errCode)
+		}
+	}
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != RATE_LIMITED {
+		t.Errorf("take past the refill: %d, want RATE_LIMITED", errCode)
+	}
+
+	// the bucket refills up to the burst only
+	clock.now = clock.now.Add(time.Hour)
+	if errCode := l.take(context.Background(), "a", "c", 4); errCode != SUCCESS {
+		t.Fatalf("take of the full burst: %d", errCode)
+	}
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != RATE_LIMITED {
+		t.Errorf("take past the full burst: %d, want RATE_LIMITED", errCode)
+	}
+	if len(clock.waits) != 0 {
+		t.Errorf("waited %v without MaxWaitMs", clock.waits)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 2, Burst: 2, MaxWaitMs: 1000})
+	if errCode := l.take(context.Background(), "a", "c", 2); errCode != SUCCESS {
+		t.Fatalf("take of the burst: %d", errCode)
+	}
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != SUCCESS {
+		t.Fatalf("take within MaxWait: %d", errCode)
+	}
+	if len(clock.waits) != 1 || clock.waits[0] != 500*time.Millisecond {
+		t.Fatalf("waits %v, want [500ms]", clock.waits)
+	}
+	// the bucket owes a token, 2 more take 1.5s
+	if errCode := l.take(context.Background(), "a", "c", 2); errCode != RATE_LIMITED {
+		t.Errorf("take past MaxWait: %d, want RATE_LIMITED", errCode)
+	}
+	if len(clock.waits) != 1 {
+		t.Errorf("waited %v for a rejected take", clock.waits[1:])
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 1, MaxWaitMs: 1000})
+	clock.block = true
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != SUCCESS {
+		t.Fatalf("take of the burst: %d", errCode)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if errCode := l.take(ctx, "a", "c", 1); errCode != REQUEST_TIMEOUT {
+		t.Fatalf("take cancelled while waiting: %d, want REQUEST_TIMEOUT", errCode)
+	}
+	// the tokens of the cancelled take are given back
+	clock.now = clock.now.Add(time.Second)
+	clock.block = false
+	if errCode := l.take(context.Background(), "a", "c", 1); errCode != SUCCESS || len(clock.waits) != 1 {
+		t.Errorf("take after refill: %d, waits %v", errCode, clock.waits)
+	}
+}
+
+func TestRateLimiterMaxCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   Limit
+		cost    float64
+		errCode uint32
+	}{
+		{"under max cost", Limit{Rate: 1, Burst: 10, MaxCost: 5}, 5, SUCCESS},
+		{"over max cost", Limit{Rate: 1, Burst: 10, MaxCost: 5}, 6, QUERY_TOO_EXPENSIVE},
+		{"max cost defaults to burst", Limit{Rate: 1, Burst: 10}, 11, QUERY_TOO_EXPENSIVE},
+		{"max cost capped by burst", Limit{Rate: 1, Burst: 10, MaxCost: 20}, 11, QUERY_TOO_EXPENSIVE},
+		{"max cost without rate", Limit{MaxCost: 5}, 6, QUERY_TOO_EXPENSIVE},
+		{"under max cost without rate", Limit{MaxCost: 5}, 5, SUCCESS},
+		{"disabled", Limit{}, 1000, SUCCESS},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, _ := newTestLimiter(test.limit)
+			if errCode := l.take(context.Background(), "a", "c", test.cost); errCode != test.errCode {
+				t.Errorf("take: %d, want %d", errCode, test.errCode)
+			}
+		})
+	}
+}
+
+func TestRateLimiterEndpoints(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1})
+	l.config.Endpoints = map[string]Limit{"cheap": {Rate: 1, Burst: 10}}
+	if errCode := l.take(context.Background(), "cheap", "c", 10); errCode != SUCCESS {
+		t.Errorf("take of the endpoint limit: %d", errCode)
+	}
+	if errCode := l.take(context.Background(), "other", "c", 10); errCode != QUERY_TOO_EXPENSIVE {
+		t.Errorf("take of the default limit: %d, want QUERY_TOO_EXPENSIVE", errCode)
+	}
+}
+
+func TestQueryCost(t *testing.T) {
+	tests := []struct {
+		name string
+		req  string
+		cost float64
+	}{
+		{"empty", `{}`, 1 + 1.0/HEIGHT_COST_UNIT},
+		{"one address", `{"Addresses":["0xa"],"EndHeight":20000}`, 3},
+		{"addresses", `{"Addresses":["0xa","0xb","0xc"],"EndHeight":10000}`, 6},
+		{"height range", `{"Address":"0xa","StartHeight":5000,"EndHeight":25000}`, 3},
+		{"empty range", `{"StartHeight":25000,"EndHeight":5000}`, 1 + 1.0/HEIGHT_COST_UNIT},
+		{"case insensitive", `{"addresses":["0xa","0xb"],"endHeight":10000}`, 4},
+		{"query strings", `{"endHeight":"10000","startHeight":"0"}`, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := make(map[string]interface{})
+			if err := decodeParams([]byte(test.req), &req); err != nil {
+				t.Fatal(err)
+			}
+			if cost := queryCost(req); cost != test.cost {
+				t.Errorf("cost %v, want %v", cost, test.cost)
+			}
+		})
+	}
+}
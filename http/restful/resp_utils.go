@@ -9,3 +9,9 @@ func PackResponse(errCode uint32) map[string]interface{} {
 	}
 	return resp
 }
+
+func PackResponseWithDesc(errCode uint32) map[string]interface{} {
+	resp := PackResponse(errCode)
+	resp["desc"] = ErrMap[errCode]
+	return resp
+}
@@ -19,11 +19,12 @@
 package restful
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -32,6 +33,8 @@ type RestClient struct {
 	restClient *http.Client
 }
 
+// NewRestClient returns a client verifying the tls certificate of the server
+// against the system roots, use SetTLSConfig to trust other roots.
 func NewRestClient() *RestClient {
 	return &RestClient{
 		restClient: &http.Client{
@@ -40,7 +43,6 @@ func NewRestClient() *RestClient {
 				DisableKeepAlives:     false,
 				IdleConnTimeout:       time.Second * 300,
 				ResponseHeaderTimeout: time.Second * 300,
-				TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
 			},
 			Timeout: time.Second * 300,
 		},
@@ -57,16 +59,41 @@ func (self *RestClient) SetRestClient(restClient *http.Client) *RestClient {
 	return self
 }
 
+// SetTLSConfig sets the tls config of the transport, e.g. to trust a private
+// ca or present a client certificate.
+func (self *RestClient) SetTLSConfig(config *tls.Config) *RestClient {
+	if transport, ok := self.restClient.Transport.(*http.Transport); ok {
+		transport.TLSClientConfig = config
+	}
+	return self
+}
+
 func (self *RestClient) SendRestRequest(addr string, data []byte) ([]byte, error) {
-	resp, err := self.restClient.Post(addr, "application/vnd.custodian.inner.api.v1+json",
-		strings.NewReader(string(data)))
+	_, body, err := self.Do(context.Background(), http.MethodPost, addr, nil, data)
+	return body, err
+}
+
+// Do sends a json request with the given headers and returns the status and
+// body of the response.
+func (self *RestClient) Do(ctx context.Context, method, addr string, header http.Header, data []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, addr, bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, fmt.Errorf("new %s request %s error:%s", method, addr, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := self.restClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http post request:%s error:%s", data, err)
+		return 0, nil, fmt.Errorf("http %s request:%s error:%w", method, data, err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read rest response body error:%s", err)
+		return resp.StatusCode, nil, fmt.Errorf("read rest response body error:%s", err)
 	}
-	return body, nil
+	return resp.StatusCode, body, nil
 }
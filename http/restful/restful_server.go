@@ -2,88 +2,338 @@
 package restful
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"github.com/polynetwork/distribute-check/events"
 	"github.com/polynetwork/distribute-check/http/common"
+	"github.com/polynetwork/distribute-check/http/openapi"
 	"github.com/polynetwork/distribute-check/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
 
+var logger = log.WithComponent(log.COMPONENT_RESTFUL)
+
 type ApiServer interface {
 	Start() error
-	Stop()
+	Stop(ctx context.Context) error
 }
 
-type handler func(map[string]interface{}) map[string]interface{}
+// handler decodes the params of a v1 action into its request and handles it
+type handler func(ctx context.Context, params []byte) (interface{}, error)
 
 type Action struct {
 	sync.RWMutex
-	name    string
-	handler handler
+	name     string
+	scope    string
+	handler  handler
+	stream   streamFunc // csv and ndjson rows, nil if not supported
+	request  interface{}
+	response interface{}
+	schema   *openapi.Schema
+}
+
+// Config of the restful server
+type Config struct {
+	Host    string // bind address, all interfaces if empty
+	Port    uint64
+	RpcPath string // path of the json rpc endpoint
+	RpcPort uint64 // serve json rpc on its own port if set and differs from Port
+	Bus     *events.Bus
+
+	RequestTimeout time.Duration
+	MaxBodySize    int64
+	KeyStore       KeyStore         // authentication is disabled if nil
+	RateLimit      *RateLimitConfig // rate limiting is disabled if nil
+
+	// serve https if set, client certificates are verified against ClientCAFile if set
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	// checks of /healthz, /readyz runs them along with Readiness
+	Liveness  []Probe
+	Readiness []Probe
 }
 
 type restServer struct {
-	router   *Router
-	port     uint64
-	listener net.Listener
-	server   *http.Server
-	postMap  map[string]Action //post method map
-	getMap   map[string]Action //get method map
+	config      *Config
+	router      *Router
+	rpcRouter   *Router
+	listener    net.Listener
+	rpcListener net.Listener
+	server      *http.Server
+	rpcServer   *http.Server
+	postMap     map[string]*Action //post method map
+	getMap      map[string]*Action //get method map
+	methodMap   map[string]*Action //json rpc method map
+	hub         *wsHub
+	openapi     []byte
+	limiter     *rateLimiter
+	tokens      *tokenCache // websocket tokens, nil unless both auth and the websocket are enabled
+	tls         *tlsReloader
+	lock        sync.Mutex // guards server, rpcServer and hub
 }
 
 // init restful server
-func InitRestServer(web Web, port uint64) ApiServer {
+func InitRestServer(web Web, config *Config) ApiServer {
 	rt := &restServer{
-		port: port,
+		config: config,
+	}
+	if config.RateLimit != nil {
+		rt.limiter = newRateLimiter(config.RateLimit)
+	}
+	if config.KeyStore != nil && config.Bus != nil {
+		rt.tokens = newTokenCache()
 	}
 
 	rt.router = NewRouter()
-	rt.getMap = make(map[string]Action)
-	rt.postMap = make(map[string]Action)
+	rt.getMap = make(map[string]*Action)
+	rt.postMap = make(map[string]*Action)
+	rt.methodMap = make(map[string]*Action)
 	rt.registryRestServerAction(web)
 	rt.initGetHandler()
 	rt.initPostHandler()
+	rt.initRpcHandler()
+	rt.initOpenApiHandler()
+	rt.initMetricsHandler()
+	rt.initProbeHandler()
+	rt.initWebsocketHandler()
+	rt.initDashboardHandler()
+	rt.initMiddleware()
 	return rt
 }
 
 // resigtry handler method
 func (this *restServer) registryRestServerAction(web Web) {
-	postMethodMap := map[string]Action{
-		common.GETREWARDS: {name: common.ACTION_GETREWARDS, handler: web.GetRewards},
-		common.GETGASFEE:  {name: common.ACTION_GETGASFEE, handler: web.GetGasFee},
+	postMethodMap := map[string]*Action{
+		common.GETREWARDS:         newAction(common.ACTION_GETREWARDS, web.GetRewards, web.StreamRewards),
+		common.GETGASFEE:          newAction(common.ACTION_GETGASFEE, web.GetGasFee, web.StreamGasFee),
+		common.GETREWARDBREAKDOWN: newAction(common.ACTION_GETREWARDBREAKDOWN, web.GetRewardBreakdown, web.StreamRewardBreakdown),
+		common.EXPLAINREWARDS:     newAction[common.ExplainRewardsRequest, common.ExplainRewardsResponse](common.ACTION_EXPLAINREWARDS, web.ExplainRewards, nil),
+	}
+	doc := openapi.NewDocument("distribute-check", "v1")
+	for path, action := range postMethodMap {
+		this.methodMap[action.name] = action
+		doc.AddPost(path, action.name, action.request, action.response)
+		if action.stream != nil {
+			doc.AddFormats(http.MethodPost, path)
+		}
 	}
 	this.postMap = postMethodMap
+
+	typedActions := []typedAction{
+		newStreamingTypedAction(http.MethodGet, common.V2_ADDRESS_REWARDS, common.ACTION_ADDRESS_REWARDS, web.AddressRewards,
+			func(ctx context.Context, req *common.AddressRewardsRequest, w RowWriter) error {
+				return web.StreamRewards(ctx, &common.GetRewardsRequest{Addresses: []string{req.Address},
+					EndHeight: req.EndHeight, Unit: req.Unit, Decimals: req.Decimals}, w)
+			}),
+		newStreamingTypedAction(http.MethodGet, common.V2_ADDRESS_GASFEE, common.ACTION_ADDRESS_GASFEE, web.AddressGasFee,
+			func(ctx context.Context, req *common.AddressGasFeeRequest, w RowWriter) error {
+				return web.StreamGasFee(ctx, &common.GetGasFeeRequest{Addresses: []string{req.Address},
+					EndHeight: req.EndHeight, Unit: req.Unit, Decimals: req.Decimals}, w)
+			}),
+		newStreamingTypedAction(http.MethodGet, common.V2_ADDRESS_BREAKDOWN, common.ACTION_ADDRESS_BREAKDOWN, web.AddressRewardBreakdown,
+			func(ctx context.Context, req *common.AddressRewardBreakdownRequest, w RowWriter) error {
+				return web.StreamRewardBreakdown(ctx, &common.GetRewardBreakdownRequest{Address: req.Address,
+					StartHeight: req.StartHeight, EndHeight: req.EndHeight, Unit: req.Unit, Decimals: req.Decimals}, w)
+			}),
+		newTypedAction(http.MethodGet, common.V2_ADDRESS_EXPLAIN, common.ACTION_ADDRESS_EXPLAIN, web.AddressRewardsExplain),
+		newTypedAction(http.MethodGet, common.V2_CACHE_STATS, common.ACTION_CACHE_STATS, web.CacheStats),
+		newTypedAction(http.MethodGet, common.V2_STATUS, common.ACTION_STATUS, web.Status),
+		newTypedAction(http.MethodGet, common.V2_VALIDATORS, common.ACTION_VALIDATORS, web.Validators),
+		newTypedAction(http.MethodGet, common.V2_BLOCKS, common.ACTION_BLOCKS, web.Blocks),
+		newTypedAction(http.MethodGet, common.V2_BLOCK_DUST, common.ACTION_BLOCK_DUST, web.BlockDust),
+		newTypedAction(http.MethodGet, common.V2_MISMATCHES, common.ACTION_MISMATCHES, web.Mismatches),
+		newAdminTypedAction(http.MethodGet, common.V2_LOG_LEVELS, common.ACTION_GET_LOG_LEVELS, getLogLevels),
+		newAdminTypedAction(http.MethodPut, common.V2_LOG_LEVELS, common.ACTION_SET_LOG_LEVELS, setLogLevels),
+	}
+	if this.tokens != nil {
+		typedActions = append(typedActions, newTypedAction(http.MethodPost, common.V2_WS_TOKEN, common.ACTION_WS_TOKEN, this.wsToken))
+	}
+	for _, action := range typedActions {
+		// admin actions are only served to authenticated admin keys
+		if action.scope == SCOPE_ADMIN && this.config.KeyStore == nil {
+			continue
+		}
+		handler := instrument(action.name, this.requireScope(action.scope, this.limitTyped(action.name, action.handler)))
+		if action.stream {
+			this.router.addStream(action.method, action.path, handler)
+			doc.AddFormats(action.method, action.path)
+		} else {
+			this.router.add(action.method, action.path, handler)
+		}
+		doc.AddTyped(action.method, action.path, action.name, action.request, action.response)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		logger.Errorf("registryRestServerAction - json.Marshal: %v", err)
+	}
+	this.openapi = data
+}
+
+// newAction adapts a typed handler to a v1 action, its params are decoded
+// once into Req. s streams the rows of the action, it may be nil.
+func newAction[Req, Resp any](name string, h TypedHandler[Req, Resp], s StreamHandler[Req]) *Action {
+	action := &Action{
+		name:     name,
+		scope:    SCOPE_READ,
+		request:  new(Req),
+		response: new(Resp),
+		schema:   openapi.SchemaOf(new(Req)),
+	}
+	action.handler = func(ctx context.Context, params []byte) (interface{}, error) {
+		req := new(Req)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, NewError(INVALID_PARAMS, err.Error())
+		}
+		return h(ctx, req)
+	}
+	if s != nil {
+		action.stream = func(ctx context.Context, params []byte, w RowWriter) error {
+			req := new(Req)
+			if err := json.Unmarshal(params, req); err != nil {
+				return NewError(INVALID_PARAMS, err.Error())
+			}
+			return s(ctx, req, w)
+		}
+	}
+	return action
+}
+
+// authorize, validate the request against the schema of the action and
+// charge its cost, then handle it
+func (this *restServer) invoke(r *http.Request, h *Action, req map[string]interface{}, params []byte) *common.Response {
+	if resp := this.check(r, h, req); resp != nil {
+		return resp
+	}
+	result, err := h.handler(r.Context(), params)
+	return newResponse(h.name, result, err)
+}
+
+// check authorizes the request, validates it against the schema of the
+// action and charges its cost, it returns the error response if any fails.
+func (this *restServer) check(r *http.Request, h *Action, req map[string]interface{}) *common.Response {
+	if errCode := this.authorize(r.Context(), h.scope); errCode != SUCCESS {
+		return &common.Response{Action: h.name, Error: errCode}
+	}
+	if h.schema != nil {
+		if errs := h.schema.Validate(req); len(errs) != 0 {
+			return &common.Response{Action: h.name, Error: INVALID_PARAMS, Result: errs}
+		}
+	}
+	if errCode := this.rateLimit(r, h.name, req); errCode != SUCCESS {
+		return &common.Response{Action: h.name, Error: errCode}
+	}
+	return nil
+}
+
+// decode a json object keeping numbers as json.Number
+func decodeParams(data []byte, req interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(req)
 }
 
 // start server
 func (this *restServer) Start() error {
-	retPort := this.port
+	retPort := this.config.Port
 	if retPort == 0 {
-		log.Fatal("Not configure HttpRestPort port ")
-		return nil
+		return fmt.Errorf("Not configure HttpRestPort port")
 	}
 
+	if this.config.CertFile != "" {
+		var err error
+		this.tls, err = newTlsReloader(this.config.CertFile, this.config.KeyFile, this.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("newTlsReloader error: %s", err)
+		}
+		go this.tls.watch()
+		defer this.tls.stop()
+	}
+
+	// both listeners are opened before serving, so a port that can not be
+	// bound fails Start
 	var err error
-	this.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", retPort))
+	this.listener, err = this.listen(retPort)
 	if err != nil {
-		log.Fatal("net.Listen: ", err.Error())
-		return err
+		return fmt.Errorf("net.Listen error: %s", err)
+	}
+	if this.rpcRouter != nil {
+		this.rpcListener, err = this.listen(this.config.RpcPort)
+		if err != nil {
+			this.listener.Close()
+			return fmt.Errorf("json rpc net.Listen error: %s", err)
+		}
+	}
+	logger.Infof("server start, listen %s", this.listener.Addr())
+	if this.config.Bus != nil {
+		hub := newWsHub(this.config.Bus)
+		this.lock.Lock()
+		this.hub = hub
+		this.lock.Unlock()
+		go hub.run()
+		defer hub.close()
 	}
-	log.Infof("server start, listen 0.0.0.0:%d", retPort)
-	this.server = &http.Server{Handler: this.router}
-	err = this.server.Serve(this.listener)
+	server := &http.Server{Handler: this.router}
+	var rpcServer *http.Server
+	if this.rpcRouter != nil {
+		rpcServer = &http.Server{Handler: this.rpcRouter}
+	}
+	this.lock.Lock()
+	this.server, this.rpcServer = server, rpcServer
+	this.lock.Unlock()
 
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err.Error())
-		return err
+	errs := make(chan error, 2)
+	go serve(server, this.listener, "Serve", errs)
+	if rpcServer != nil {
+		logger.Infof("json rpc server start, listen %s", this.rpcListener.Addr())
+		go serve(rpcServer, this.rpcListener, "json rpc Serve", errs)
+	}
+	// the first failure closes the other server, Start returns once both
+	// stopped serving
+	err = <-errs
+	if rpcServer != nil {
+		if err != nil {
+			server.Close()
+			rpcServer.Close()
+		}
+		if e := <-errs; err == nil {
+			err = e
+		}
 	}
+	return err
+}
 
-	return nil
+// serve sends the error of server, nil once it is shut down
+func serve(server *http.Server, listener net.Listener, name string, errs chan<- error) {
+	err := server.Serve(listener)
+	if err != nil && err != http.ErrServerClosed {
+		errs <- fmt.Errorf("%s error: %s", name, err)
+		return
+	}
+	errs <- nil
+}
+
+// listen on the configured host, with tls if configured
+func (this *restServer) listen(port uint64) (net.Listener, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(this.config.Host, strconv.FormatUint(port, 10)))
+	if err != nil {
+		return nil, err
+	}
+	if this.tls != nil {
+		listener = tls.NewListener(listener, this.tls.tlsConfig())
+	}
+	return listener, nil
 }
 
 func (this *restServer) getPath(url string) string {
@@ -103,47 +353,58 @@ func (this *restServer) getUrlParams(r *http.Request) map[string]interface{} {
 func (this *restServer) initGetHandler() {
 	for k := range this.getMap {
 		this.router.Get(k, func(w http.ResponseWriter, r *http.Request) {
-			var resp map[string]interface{}
+			var resp *common.Response
 			url := this.getPath(r.URL.Path)
 			if h, ok := this.getMap[url]; ok {
 				req := this.getUrlParams(r)
-				resp = h.handler(req)
-				resp["action"] = h.name
+				params, _ := json.Marshal(req)
+				resp = this.invoke(r, h, req, params)
 			} else {
-				resp = PackResponse(INVALID_METHOD)
-				resp["action"] = h.name
+				resp = &common.Response{Error: INVALID_METHOD}
 			}
-			this.response(w, resp)
+			writeV1Response(w, resp)
 		})
 	}
 }
 
 // init post Handler
 func (this *restServer) initPostHandler() {
-	for k := range this.postMap {
-		this.router.Post(k, func(w http.ResponseWriter, r *http.Request) {
+	for k, action := range this.postMap {
+		add := this.router.add
+		if action.stream != nil {
+			add = this.router.addStream
+		}
+		add(http.MethodPost, k, instrument(action.name, func(w http.ResponseWriter, r *http.Request) {
 
-			body, _ := ioutil.ReadAll(r.Body)
+			body, readErr := ioutil.ReadAll(r.Body)
 			defer r.Body.Close()
 
 			var req = make(map[string]interface{})
-			var resp map[string]interface{}
+			var resp *common.Response
 
 			url := this.getPath(r.URL.Path)
 			if h, ok := this.postMap[url]; ok {
-				if err := json.Unmarshal(body, &req); err == nil {
-					resp = h.handler(req)
+				if readErr != nil {
+					requestLogger(r).With("action", h.name).Errorf("read body error: %s", readErr)
+					resp = &common.Response{Action: h.name, Error: readErrorCode(readErr)}
+				} else if err := decodeParams(body, &req); err != nil {
+					requestLogger(r).With("action", h.name).Errorf("unmarshal body error: %s", err)
+					resp = &common.Response{Action: h.name, Error: ILLEGAL_DATAFORMAT}
+				} else if format := outputFormat(r); format != FORMAT_JSON && h.stream != nil {
+					if resp = this.check(r, h, req); resp == nil {
+						stream(w, r, h.name, format, func(rw RowWriter) error {
+							return h.stream(r.Context(), body, rw)
+						})
+						return
+					}
 				} else {
-					log.Error("unmarshal body error:", err)
-					resp = PackResponse(ILLEGAL_DATAFORMAT)
+					resp = this.invoke(r, h, req, body)
 				}
-				resp["action"] = h.name
 			} else {
-				resp = PackResponse(INVALID_METHOD)
-				resp["action"] = h.name
+				resp = &common.Response{Error: INVALID_METHOD}
 			}
-			this.response(w, resp)
-		})
+			writeV1Response(w, resp)
+		}))
 	}
 	//Options
 	for k := range this.postMap {
@@ -154,36 +415,111 @@ func (this *restServer) initPostHandler() {
 
 }
 func (this *restServer) write(w http.ResponseWriter, data []byte) {
+	writeData(w, data)
+}
+
+func writeData(w http.ResponseWriter, data []byte) {
 	w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
 	w.Header().Set("content-type", "application/json;charset=utf-8")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Write(data)
 }
 
-// response
-func (this *restServer) response(w http.ResponseWriter, resp map[string]interface{}) {
-	resp["desc"] = ErrMap[resp["error"].(uint32)]
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatalf("HTTP Handle - json.Marshal: %v", err)
+// init json rpc Handler
+func (this *restServer) initRpcHandler() {
+	if this.config.RpcPath == "" {
+		return
+	}
+	router := this.router
+	if this.config.RpcPort != 0 && this.config.RpcPort != this.config.Port {
+		this.rpcRouter = NewRouter()
+		router = this.rpcRouter
+	}
+	router.Post(this.config.RpcPath, instrument("jsonrpc", this.handleRpc))
+	router.Options(this.config.RpcPath, func(w http.ResponseWriter, r *http.Request) {
+		this.write(w, []byte{})
+	})
+}
+
+// init middleware chain of the routers, they share the authentication so a
+// signed request is accepted once on either
+func (this *restServer) initMiddleware() {
+	var auth Middleware
+	if this.config.KeyStore != nil {
+		public := map[string]bool{
+			common.OPENAPI:   true,
+			common.METRICS:   true,
+			common.HEALTHZ:   true,
+			common.READYZ:    true,
+			common.DASHBOARD: true,
+		}
+		auth = Auth(this.config.KeyStore, public, this.tokens)
+	}
+	this.router.Use(this.middlewares(this.router, auth)...)
+	if this.rpcRouter != nil {
+		this.rpcRouter.Use(this.middlewares(this.rpcRouter, auth)...)
+	}
+}
+
+// middlewares of the router, auth is nil if authentication is disabled
+func (this *restServer) middlewares(router *Router, auth Middleware) []Middleware {
+	middlewares := []Middleware{
+		RequestIds(),
+		AccessLog(),
+		Recovery(),
+		BodyLimit(this.config.MaxBodySize),
+		Timeout(this.config.RequestTimeout, router.Streaming),
+	}
+	if auth != nil {
+		middlewares = append(middlewares, auth)
+	}
+	return middlewares
+}
+
+// init openapi document Handler
+func (this *restServer) initOpenApiHandler() {
+	this.router.Get(common.OPENAPI, func(w http.ResponseWriter, r *http.Request) {
+		this.write(w, this.openapi)
+	})
+}
+
+// init prometheus metrics Handler
+func (this *restServer) initMetricsHandler() {
+	this.router.Get(common.METRICS, promhttp.Handler().ServeHTTP)
+}
+
+// init websocket Handler
+func (this *restServer) initWebsocketHandler() {
+	if this.config.Bus == nil {
 		return
 	}
-	this.write(w, data)
+	this.router.Get(common.WEBSOCKET, this.handleWebsocket)
 }
 
-// stop restful server
-func (this *restServer) Stop() {
-	if this.server != nil {
-		this.server.Shutdown(context.Background())
-		log.Error("Close restful ")
+// stop restful server, in flight requests are drained until ctx expires
+func (this *restServer) Stop(ctx context.Context) error {
+	this.lock.Lock()
+	server, rpcServer := this.server, this.rpcServer
+	this.lock.Unlock()
+	var err error
+	if rpcServer != nil {
+		err = rpcServer.Shutdown(ctx)
+		logger.Info("Close json rpc ")
+	}
+	if server != nil {
+		if e := server.Shutdown(ctx); e != nil {
+			err = e
+		}
+		logger.Info("Close restful ")
 	}
+	return err
 }
 
 // restart server
 func (this *restServer) Restart(cmd map[string]interface{}) map[string]interface{} {
 	go func() {
 		time.Sleep(time.Second)
-		this.Stop()
+		this.Stop(context.Background())
 		time.Sleep(time.Second)
 		go this.Start()
 	}()
@@ -10,39 +10,77 @@ import (
 
 type paramsMap map[string]string
 
+type contextKey int
+
+const (
+	paramsKey contextKey = iota
+	requestIdKey
+	apiKeyKey
+)
+
+// Params returns the path parameters the router matched for the request.
+func Params(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey).(paramsMap)
+	return params
+}
+
+// Param returns the path parameter with the name, or "" if absent.
+func Param(r *http.Request, name string) string {
+	return Params(r)[name]
+}
+
 // http router
 type Route struct {
 	Method  string
 	Path    *regexp.Regexp
 	Params  []string
 	Handler http.HandlerFunc
+	Stream  bool // answers csv and ndjson streams
 }
 type Router struct {
-	routes []*Route
+	routes      []*Route
+	middlewares []Middleware
+	handler     http.Handler // dispatch wrapped in the middlewares
 }
 
 func NewRouter() *Router {
-	return &Router{}
+	r := &Router{}
+	r.handler = http.HandlerFunc(r.dispatch)
+	return r
 }
 
 func (this *Router) Try(path string, method string) (http.HandlerFunc, paramsMap, error) {
+	route := this.match(path, method)
+	if route == nil {
+		return nil, paramsMap{}, errors.New("Route not found")
+	}
+	params := paramsMap{}
+	if len(route.Params) > 0 {
+		params = parseParams(route, path)
+	}
+	return route.Handler, params, nil
+}
 
+func (this *Router) match(path string, method string) *Route {
 	for _, route := range this.routes {
-		if route.Method == method {
-			match := route.Path.MatchString(path)
-			if match == false {
-				continue
-			}
-			params := paramsMap{}
-			if len(route.Params) > 0 {
-				params = parseParams(route, path)
-			}
-			return route.Handler, params, nil
-
+		if route.Method == method && route.Path.MatchString(path) {
+			return route
 		}
 	}
-	return nil, paramsMap{}, errors.New("Route not found")
+	return nil
+}
+
+// Streaming reports whether the route of the request answers streams.
+func (this *Router) Streaming(r *http.Request) bool {
+	route := this.match(r.URL.Path, r.Method)
+	return route != nil && route.Stream
+}
 
+// addStream adds a route answering csv and ndjson streams, its requests are
+// not bounded by the Timeout middleware when they negotiate a stream
+func (this *Router) addStream(method string, path string, handler http.HandlerFunc) {
+	this.add(method, path, handler)
+	this.routes[len(this.routes)-1].Stream = true
 }
 
 func (this *Router) add(method string, path string, handler http.HandlerFunc) {
@@ -96,13 +134,28 @@ func (r *Router) Options(path string, handler http.HandlerFunc) {
 	r.add("OPTIONS", path, handler)
 }
 
+// Use appends middlewares to the chain, the first one added runs outermost.
+// The chain is built once here, Use is not safe while serving.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+	var handler http.Handler = http.HandlerFunc(r.dispatch)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	r.handler = handler
+}
+
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) {
 	handler, params, err := r.Try(req.URL.Path, req.Method)
 	if err != nil {
 		http.NotFound(w, req)
 		return
 	}
-	ctx := context.WithValue(req.Context(), "params", params)
+	ctx := context.WithValue(req.Context(), paramsKey, params)
 	handler(w, req.WithContext(ctx))
 }
 
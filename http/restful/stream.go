@@ -0,0 +1,182 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/polynetwork/distribute-check/http/openapi"
+)
+
+const (
+	FORMAT_JSON   = "json"
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+
+	// rows written between two flushes of a stream
+	STREAM_FLUSH_ROWS = 100
+)
+
+// outputFormat negotiates the format of the response, the format query
+// parameter takes precedence over the Accept header.
+func outputFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case FORMAT_CSV:
+		return FORMAT_CSV
+	case FORMAT_NDJSON:
+		return FORMAT_NDJSON
+	case FORMAT_JSON:
+		return FORMAT_JSON
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		switch strings.TrimSpace(strings.Split(part, ";")[0]) {
+		case openapi.CONTENT_CSV:
+			return FORMAT_CSV
+		case openapi.CONTENT_NDJSON, "application/ndjson":
+			return FORMAT_NDJSON
+		}
+	}
+	return FORMAT_JSON
+}
+
+// RowWriter writes the rows of a streamed response as csv or ndjson.
+type RowWriter interface {
+	// Header starts the response, the columns name the values of each row
+	Header(columns ...string) error
+	Row(values ...interface{}) error
+}
+
+// StreamHandler writes the rows answering req, an *Error returned before
+// Header sets the error code of a json response.
+type StreamHandler[Req any] func(ctx context.Context, req *Req, w RowWriter) error
+
+// streamFunc streams the rows of a v1 action from its params
+type streamFunc func(ctx context.Context, params []byte, w RowWriter) error
+
+type rowWriter struct {
+	w       http.ResponseWriter
+	format  string
+	name    string
+	columns []string
+	csv     *csv.Writer
+	rows    int
+	started bool
+}
+
+func newRowWriter(w http.ResponseWriter, format, name string) *rowWriter {
+	return &rowWriter{w: w, format: format, name: name}
+}
+
+func (this *rowWriter) Header(columns ...string) error {
+	if this.started {
+		return fmt.Errorf("Header, already written")
+	}
+	this.started = true
+	this.columns = columns
+	h := this.w.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	if this.format == FORMAT_CSV {
+		h.Set("content-type", openapi.CONTENT_CSV+";charset=utf-8")
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", this.name+".csv"))
+		this.w.WriteHeader(http.StatusOK)
+		this.csv = csv.NewWriter(this.w)
+		return this.csv.Write(columns)
+	}
+	h.Set("content-type", openapi.CONTENT_NDJSON)
+	this.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (this *rowWriter) Row(values ...interface{}) error {
+	if !this.started {
+		return fmt.Errorf("Row, Header not written")
+	}
+	if len(values) != len(this.columns) {
+		return fmt.Errorf("Row, %d values for %d columns", len(values), len(this.columns))
+	}
+	if this.format == FORMAT_CSV {
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = fmt.Sprint(v)
+		}
+		if err := this.csv.Write(record); err != nil {
+			return err
+		}
+	} else {
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(this.columns[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("Row, json.Marshal error: %s", err)
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteString("}\n")
+		if _, err := this.w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	this.rows++
+	if this.rows%STREAM_FLUSH_ROWS == 0 {
+		return this.flush()
+	}
+	return nil
+}
+
+func (this *rowWriter) flush() error {
+	if this.csv != nil {
+		this.csv.Flush()
+		if err := this.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := this.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// stream runs the stream of an action. Errors before the first row are
+// answered as json, later ones abort the response so the client sees it is
+// truncated.
+func stream(w http.ResponseWriter, r *http.Request, name, format string, run func(RowWriter) error) {
+	rw := newRowWriter(w, format, name)
+	err := run(rw)
+	if err == nil {
+		if !rw.started {
+			err = rw.Header()
+		}
+		if err == nil {
+			err = rw.flush()
+		}
+	}
+	if err == nil {
+		return
+	}
+	if rw.started {
+		requestLogger(r).With("action", name, "rows", rw.rows).Errorf("stream aborted: %s", err)
+		panic(http.ErrAbortHandler)
+	}
+	code, desc := INTERNAL_ERROR, err.Error()
+	apiErr := new(Error)
+	if errors.As(err, &apiErr) {
+		code, desc = apiErr.Code, apiErr.Desc
+	}
+	resp := PackResponseWithDesc(code)
+	resp["action"] = name
+	resp["result"] = desc
+	data, _ := json.Marshal(resp)
+	writeData(w, data)
+}
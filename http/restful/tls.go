@@ -0,0 +1,97 @@
+package restful
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// tlsReloader serves the certificate, key and client CA files and reloads
+// them when the process receives SIGHUP.
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	lock         sync.RWMutex
+	config       *tls.Config
+	done         chan struct{}
+}
+
+func newTlsReloader(certFile, keyFile, clientCAFile string) (*tlsReloader, error) {
+	r := &tlsReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		done:         make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *tlsReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls.LoadX509KeyPair error: %s", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.clientCAFile != "" {
+		data, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca file error: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in client ca file %s", r.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.lock.Lock()
+	r.config = config
+	r.lock.Unlock()
+	return nil
+}
+
+// tlsConfig returns a config handing every connection the latest loaded config
+func (r *tlsReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+func (r *tlsReloader) watch() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ch:
+			if err := r.reload(); err != nil {
+				logger.With("cert", r.certFile).Errorf("reload tls certificate error: %s", err)
+			} else {
+				logger.With("cert", r.certFile).Info("reloaded tls certificate")
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *tlsReloader) stop() {
+	close(r.done)
+}
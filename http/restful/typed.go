@@ -0,0 +1,256 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/polynetwork/distribute-check/http/common"
+	"github.com/polynetwork/distribute-check/http/openapi"
+)
+
+// Error is an api error carrying one of the codes of ErrMap.
+type Error struct {
+	Code uint32
+	Desc string
+}
+
+func NewError(code uint32, desc string) *Error {
+	return &Error{Code: code, Desc: desc}
+}
+
+func (e *Error) Error() string {
+	if e.Desc == "" {
+		return ErrMap[e.Code]
+	}
+	return fmt.Sprintf("%s: %s", ErrMap[e.Code], e.Desc)
+}
+
+// TypedHandler handles a request decoded into Req. A returned *Error sets the
+// error code of the response, any other error is an INTERNAL_ERROR.
+type TypedHandler[Req, Resp any] func(ctx context.Context, req *Req) (*Resp, error)
+
+type typedAction struct {
+	name     string
+	method   string
+	path     string
+	scope    string
+	handler  http.HandlerFunc
+	request  interface{}
+	response interface{}
+	stream   bool
+}
+
+func newTypedAction[Req, Resp any](method, path, name string, h TypedHandler[Req, Resp]) typedAction {
+	return typedAction{
+		name:     name,
+		method:   method,
+		path:     path,
+		scope:    SCOPE_READ,
+		handler:  Handle(name, h),
+		request:  new(Req),
+		response: new(Resp),
+	}
+}
+
+func newStreamingTypedAction[Req, Resp any](method, path, name string, h TypedHandler[Req, Resp], s StreamHandler[Req]) typedAction {
+	action := newTypedAction(method, path, name, h)
+	action.handler = HandleStream(name, h, s)
+	action.stream = true
+	return action
+}
+
+// newAdminTypedAction is newTypedAction served only to admin keys
+func newAdminTypedAction[Req, Resp any](method, path, name string, h TypedHandler[Req, Resp]) typedAction {
+	action := newTypedAction(method, path, name, h)
+	action.scope = SCOPE_ADMIN
+	return action
+}
+
+// Handle adapts a typed handler to the router. The json body is decoded once
+// into Req, then fields tagged `path:"name"` and `query:"name"` are bound from
+// the path and query parameters.
+func Handle[Req, Resp any](action string, h TypedHandler[Req, Resp]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+		if resp := bind(r, action, req); resp != nil {
+			writeResponse(w, resp)
+			return
+		}
+		result, err := h(r.Context(), req)
+		writeResponse(w, newResponse(action, result, err))
+	}
+}
+
+// newResponse answers the result of an action, or its error
+func newResponse(action string, result interface{}, err error) *common.Response {
+	resp := &common.Response{Action: action}
+	if err != nil {
+		apiErr := new(Error)
+		if errors.As(err, &apiErr) {
+			resp.Error = apiErr.Code
+			resp.Result = apiErr.Desc
+		} else {
+			resp.Error = INTERNAL_ERROR
+			resp.Result = err.Error()
+		}
+	} else {
+		resp.Error = SUCCESS
+		resp.Result = result
+	}
+	return resp
+}
+
+// HandleStream is Handle answering csv or ndjson rows from s when the
+// request negotiates one of these formats.
+func HandleStream[Req, Resp any](action string, h TypedHandler[Req, Resp], s StreamHandler[Req]) http.HandlerFunc {
+	handle := Handle(action, h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := outputFormat(r)
+		if format == FORMAT_JSON {
+			handle(w, r)
+			return
+		}
+		req := new(Req)
+		if resp := bind(r, action, req); resp != nil {
+			writeResponse(w, resp)
+			return
+		}
+		stream(w, r, action, format, func(rw RowWriter) error {
+			return s(r.Context(), req, rw)
+		})
+	}
+}
+
+// bind binds the request, returning the error response if it is invalid
+func bind(r *http.Request, action string, req interface{}) *common.Response {
+	errs, err := bindRequest(r, req)
+	if err != nil {
+		return &common.Response{Action: action, Error: readErrorCode(err), Result: err.Error()}
+	}
+	if len(errs) != 0 {
+		return &common.Response{Action: action, Error: INVALID_PARAMS, Result: errs}
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, resp *common.Response) {
+	if resp.Desc == "" {
+		resp.Desc = ErrMap[resp.Error]
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		logger.Errorf("HTTP Handle - json.Marshal: %v", err)
+		return
+	}
+	writeData(w, data)
+}
+
+// writeV1Response answers a v1 action, the message of an error is its desc
+// as v1 clients read it there.
+func writeV1Response(w http.ResponseWriter, resp *common.Response) {
+	if message, ok := resp.Result.(string); ok && resp.Error != SUCCESS && message != "" {
+		resp.Desc = message
+		resp.Result = nil
+	}
+	writeResponse(w, resp)
+}
+
+// bindRequest returns an error if the body can not be read, and the
+// invalid fields otherwise
+func bindRequest(r *http.Request, req interface{}) ([]openapi.FieldError, error) {
+	errs := make([]openapi.FieldError, 0)
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(body)) != 0 {
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(req); err != nil {
+				return append(errs, openapi.FieldError{Field: "$", Message: err.Error()}), nil
+			}
+		}
+	}
+
+	params := Params(r)
+	query := r.URL.Query()
+	v := reflect.ValueOf(req).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		var name string
+		var raw []string
+		if name = f.Tag.Get("path"); name != "" {
+			if value, ok := params[name]; ok {
+				raw = []string{value}
+			}
+		} else if name = f.Tag.Get("query"); name != "" {
+			raw = query[name]
+		} else {
+			continue
+		}
+		if len(raw) == 0 {
+			if f.Tag.Get("validate") == "required" {
+				errs = append(errs, openapi.FieldError{Field: name, Message: "required field missing"})
+			}
+			continue
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			errs = append(errs, openapi.FieldError{Field: name, Message: err.Error()})
+		}
+	}
+	return errs, nil
+}
+
+func setField(field reflect.Value, raw []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		value := reflect.New(field.Type().Elem())
+		if err := setField(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+	case reflect.String:
+		field.SetString(raw[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw[0])
+		if err != nil {
+			return fmt.Errorf("expected boolean, got %q", raw[0])
+		}
+		field.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw[0], 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected unsigned integer, got %q", raw[0])
+		}
+		field.SetUint(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw[0], 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected integer, got %q", raw[0])
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported parameter type %s", field.Type())
+		}
+		values := make([]string, 0, len(raw))
+		for _, r := range raw {
+			values = append(values, strings.Split(r, ",")...)
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported parameter type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/polynetwork/distribute-check/events"
+	"github.com/polynetwork/distribute-check/http/common"
+)
+
+const (
+	WS_WRITE_WAIT    = 10 * time.Second
+	WS_PONG_WAIT     = 60 * time.Second
+	WS_PING_PERIOD   = WS_PONG_WAIT * 9 / 10
+	WS_MAX_MESSAGE   = 4096
+	WS_HUB_BUFFER    = 256
+	WS_CLIENT_BUFFER = 64
+
+	WS_ACTION_SUBSCRIBE   = "subscribe"
+	WS_ACTION_UNSUBSCRIBE = "unsubscribe"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+type wsRequest struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+type wsResponse struct {
+	Action string   `json:"action"`
+	Desc   string   `json:"desc"`
+	Error  uint32   `json:"error"`
+	Topics []string `json:"topics"`
+}
+
+// wsClient is a websocket connection with its own send buffer, a client that
+// can not keep up with the events is disconnected.
+type wsClient struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	lock   sync.RWMutex
+	topics map[string]bool
+}
+
+func (c *wsClient) subscribed(topic string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.topics[topic]
+}
+
+func (c *wsClient) subscribedTopics() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	topics := make([]string, 0, len(c.topics))
+	for _, t := range events.Topics {
+		if c.topics[t] {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// wsHub fans the events of the bus out to the websocket clients
+type wsHub struct {
+	lock    sync.RWMutex
+	clients map[*wsClient]bool
+	sub     *events.Subscription
+}
+
+func newWsHub(bus *events.Bus) *wsHub {
+	return &wsHub{
+		clients: make(map[*wsClient]bool),
+		sub:     bus.Subscribe(WS_HUB_BUFFER),
+	}
+}
+
+func (h *wsHub) run() {
+	for ev := range h.sub.C() {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			logger.Errorf("wsHub - json.Marshal: %v", err)
+			continue
+		}
+		slow := make([]*wsClient, 0)
+		h.lock.RLock()
+		for c := range h.clients {
+			if !c.subscribed(ev.Topic) {
+				continue
+			}
+			select {
+			case c.send <- data:
+			default:
+				slow = append(slow, c)
+			}
+		}
+		h.lock.RUnlock()
+		for _, c := range slow {
+			logger.With("remote", c.conn.RemoteAddr()).Warn("websocket client too slow, disconnecting")
+			h.remove(c)
+		}
+	}
+}
+
+func (h *wsHub) add(c *wsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.clients[c] = true
+}
+
+// remove the client and close its send buffer, which ends its write loop
+func (h *wsHub) remove(c *wsClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// enqueue a reply to the client, a full buffer disconnects it
+func (h *wsHub) enqueue(c *wsClient, data []byte) {
+	h.lock.RLock()
+	ok := h.clients[c]
+	if ok {
+		select {
+		case c.send <- data:
+		default:
+			ok = false
+		}
+	}
+	h.lock.RUnlock()
+	if !ok {
+		h.remove(c)
+	}
+}
+
+func (h *wsHub) close() {
+	h.sub.Unsubscribe()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// wsToken issues a token of the key of the request, browsers upgrade with it
+// in the token query parameter as they can not set the api key headers.
+func (this *restServer) wsToken(ctx context.Context, req *common.WsTokenRequest) (*common.WsTokenResponse, error) {
+	key := ApiKeyFromContext(ctx)
+	if key == nil {
+		return nil, NewError(UNAUTHORIZED, ErrMap[UNAUTHORIZED])
+	}
+	token, expiry, err := this.tokens.issue(key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &common.WsTokenResponse{Token: token, Expires: expiry.Unix()}, nil
+}
+
+// serve websocket subscriptions
+func (this *restServer) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	this.lock.Lock()
+	hub := this.hub
+	this.lock.Unlock()
+	if hub == nil {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		requestLogger(r).Errorf("websocket upgrade error: %s", err)
+		return
+	}
+	c := &wsClient{
+		conn:   conn,
+		send:   make(chan []byte, WS_CLIENT_BUFFER),
+		topics: make(map[string]bool),
+	}
+	hub.add(c)
+	go this.wsWrite(c)
+	this.wsRead(hub, c)
+}
+
+func (this *restServer) wsRead(hub *wsHub, c *wsClient) {
+	defer func() {
+		hub.remove(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(WS_MAX_MESSAGE)
+	c.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	})
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logger.With("remote", c.conn.RemoteAddr()).Errorf("websocket read error: %s", err)
+			}
+			return
+		}
+		resp := handleWsRequest(c, message)
+		data, err := json.Marshal(resp)
+		if err != nil {
+			logger.Errorf("websocket - json.Marshal: %v", err)
+			continue
+		}
+		hub.enqueue(c, data)
+	}
+}
+
+func handleWsRequest(c *wsClient, message []byte) *wsResponse {
+	req := new(wsRequest)
+	if err := json.Unmarshal(message, req); err != nil {
+		return &wsResponse{Error: ILLEGAL_DATAFORMAT, Desc: ErrMap[ILLEGAL_DATAFORMAT]}
+	}
+	resp := &wsResponse{Action: req.Action}
+	if req.Action != WS_ACTION_SUBSCRIBE && req.Action != WS_ACTION_UNSUBSCRIBE {
+		resp.Error = INVALID_METHOD
+		resp.Desc = ErrMap[INVALID_METHOD]
+		return resp
+	}
+	for _, topic := range req.Topics {
+		if !validTopic(topic) {
+			resp.Error = INVALID_PARAMS
+			resp.Desc = ErrMap[INVALID_PARAMS]
+			return resp
+		}
+	}
+	c.lock.Lock()
+	for _, topic := range req.Topics {
+		if req.Action == WS_ACTION_SUBSCRIBE {
+			c.topics[topic] = true
+		} else {
+			delete(c.topics, topic)
+		}
+	}
+	c.lock.Unlock()
+	resp.Desc = ErrMap[SUCCESS]
+	resp.Topics = c.subscribedTopics()
+	return resp
+}
+
+func validTopic(topic string) bool {
+	for _, t := range events.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *restServer) wsWrite(c *wsClient) {
+	ticker := time.NewTicker(WS_PING_PERIOD)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_WAIT))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_WAIT))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package lifecycle starts, supervises and stops the components of the
+// service.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/distribute-check/log"
+)
+
+// Component is a long running part of the service.
+type Component interface {
+	Name() string
+	// Start runs the component until ctx is done or it fails.
+	Start(ctx context.Context) error
+	// Stop drains the component once ctx of Start is done, it returns when
+	// drained or when ctx expires.
+	Stop(ctx context.Context) error
+	// Health returns nil while the component works.
+	Health() error
+}
+
+// Funcs adapts functions to a Component, nil functions do nothing. A nil
+// StartFunc blocks until ctx is done.
+type Funcs struct {
+	ComponentName string
+	StartFunc     func(ctx context.Context) error
+	StopFunc      func(ctx context.Context) error
+	HealthFunc    func() error
+}
+
+func (f *Funcs) Name() string {
+	return f.ComponentName
+}
+
+func (f *Funcs) Start(ctx context.Context) error {
+	if f.StartFunc == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return f.StartFunc(ctx)
+}
+
+func (f *Funcs) Stop(ctx context.Context) error {
+	if f.StopFunc == nil {
+		return nil
+	}
+	return f.StopFunc(ctx)
+}
+
+func (f *Funcs) Health() error {
+	if f.HealthFunc == nil {
+		return nil
+	}
+	return f.HealthFunc()
+}
+
+type RestartPolicy int
+
+const (
+	// a failing component shuts the service down
+	RestartNever RestartPolicy = iota
+	// a component returning an error or panicking is restarted
+	RestartOnFailure
+	// a component is restarted whenever Start returns
+	RestartAlways
+)
+
+type Options struct {
+	Restart     RestartPolicy
+	MaxRestarts int // 0 for no limit
+	Backoff     time.Duration
+}
+
+const (
+	STATE_STARTING = "starting"
+	STATE_RUNNING  = "running"
+	STATE_FAILED   = "failed"
+	STATE_STOPPED  = "stopped"
+)
+
+type supervised struct {
+	component Component
+	options   Options
+	lock      sync.RWMutex
+	state     string
+	restarts  int
+	lastErr   error
+	done      chan struct{}
+}
+
+func (s *supervised) setState(state string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.state = state
+	if err != nil {
+		s.lastErr = err
+	}
+}
+
+// Supervisor starts components in the order they were added and stops them
+// in reverse order.
+type Supervisor struct {
+	components  []*supervised
+	stopTimeout time.Duration
+}
+
+func NewSupervisor(stopTimeout time.Duration) *Supervisor {
+	return &Supervisor{stopTimeout: stopTimeout}
+}
+
+func (s *Supervisor) Add(c Component, options Options) {
+	s.components = append(s.components, &supervised{component: c, options: options, state: STATE_STARTING, done: make(chan struct{})})
+}
+
+// Done is closed once the added component c stopped for good, the Stop of a
+// component that must outlive c waits on it regardless of the stop timeout.
+func (s *Supervisor) Done(c Component) <-chan struct{} {
+	for _, supervised := range s.components {
+		if supervised.component == c {
+			return supervised.done
+		}
+	}
+	panic(fmt.Sprintf("lifecycle: component %s not added", c.Name()))
+}
+
+// Run starts every component and supervises them until ctx is done or a
+// component fails for good, then stops them all within the stop timeout.
+func (s *Supervisor) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	failed := make(chan error, len(s.components))
+	for _, c := range s.components {
+		go s.supervise(runCtx, c, failed)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Info("quiting from signal...")
+	case err = <-failed:
+		log.Errorf("shutting down: %s", err)
+	}
+	cancel()
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), s.stopTimeout)
+	defer stopCancel()
+	for i := len(s.components) - 1; i >= 0; i-- {
+		c := s.components[i]
+		name := c.component.Name()
+		if e := c.component.Stop(stopCtx); e != nil {
+			log.Errorf("stop %s error: %s", name, e)
+		}
+		select {
+		case <-c.done:
+		case <-stopCtx.Done():
+		}
+		select {
+		case <-c.done:
+			log.Infof("%s stopped", name)
+		default:
+			log.Errorf("%s did not stop within %s", name, s.stopTimeout)
+		}
+	}
+	return err
+}
+
+func (s *Supervisor) supervise(ctx context.Context, c *supervised, failed chan<- error) {
+	defer close(c.done)
+	name := c.component.Name()
+	for {
+		c.setState(STATE_RUNNING, nil)
+		err := runComponent(ctx, c.component)
+		if ctx.Err() != nil {
+			c.setState(STATE_STOPPED, err)
+			return
+		}
+		if err == nil && c.options.Restart != RestartAlways {
+			log.Infof("%s finished", name)
+			c.setState(STATE_STOPPED, nil)
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("%s exited", name)
+		}
+		c.lock.Lock()
+		c.restarts++
+		restarts := c.restarts
+		c.lock.Unlock()
+		if c.options.Restart == RestartNever || (c.options.MaxRestarts > 0 && restarts > c.options.MaxRestarts) {
+			c.setState(STATE_FAILED, err)
+			failed <- fmt.Errorf("%s failed: %s", name, err)
+			return
+		}
+		log.Errorf("%s failed, restarting (%d): %s", name, restarts, err)
+		c.setState(STATE_STARTING, err)
+		select {
+		case <-time.After(c.options.Backoff):
+		case <-ctx.Done():
+			c.setState(STATE_STOPPED, nil)
+			return
+		}
+	}
+}
+
+// runComponent starts the component, turning a panic into an error
+func runComponent(ctx context.Context, c Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return c.Start(ctx)
+}
+
+// ComponentHealth is the health of a supervised component.
+type ComponentHealth struct {
+	Name     string
+	State    string
+	Restarts int
+	Error    string
+}
+
+// Health returns the state of every component, Error is set if the
+// component is not running or reports itself unhealthy.
+func (s *Supervisor) Health() []ComponentHealth {
+	r := make([]ComponentHealth, 0, len(s.components))
+	for _, c := range s.components {
+		c.lock.RLock()
+		h := ComponentHealth{Name: c.component.Name(), State: c.state, Restarts: c.restarts}
+		lastErr := c.lastErr
+		c.lock.RUnlock()
+		if h.State != STATE_RUNNING {
+			if lastErr != nil {
+				h.Error = lastErr.Error()
+			} else {
+				h.Error = h.State
+			}
+		} else if err := c.component.Health(); err != nil {
+			h.Error = err.Error()
+		}
+		r = append(r, h)
+	}
+	return r
+}
+
+// Check fails if any component is not running or unhealthy.
+func (s *Supervisor) Check(ctx context.Context) error {
+	failed := make([]string, 0)
+	for _, h := range s.Health() {
+		if h.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", h.Name, h.Error))
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
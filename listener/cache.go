@@ -0,0 +1,102 @@
+package listener
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/polynetwork/distribute-check/cache"
+)
+
+const (
+	ACCUMULATE_REWARDS = "rewards"
+	ACCUMULATE_GASFEE  = "gasfee"
+)
+
+type accumulateKey struct {
+	kind      string
+	address   string
+	endHeight uint64
+}
+
+// accumulateCache holds accumulations ending below the track height minus
+// the confirmations, which do not change unless history is rescanned.
+// Invalidation is driven by the track height moving backwards only: a reorg
+// is not detected by itself, so reorgs deeper than the confirmations leave
+// stale entries until history is rescanned from below them.
+type accumulateCache struct {
+	lru           *cache.LRU[accumulateKey, *big.Int]
+	confirmations uint64
+	trackHeight   uint64 // next height to handle
+	generation    uint64 // bumped on invalidation
+	maxEndHeight  uint64 // highest end height ever cached
+}
+
+// EnableCache caches up to size accumulation queries ending more than
+// confirmations blocks below the track height.
+func (v *Listener) EnableCache(size int, confirmations uint64) {
+	if size <= 0 {
+		return
+	}
+	v.cache = &accumulateCache{lru: cache.NewLRU[accumulateKey, *big.Int](size), confirmations: confirmations}
+}
+
+// setTrackHeight records the next height to handle, a lower height than
+// before means history is rescanned from it.
+func (v *Listener) setTrackHeight(height uint64) {
+	if v.cache == nil {
+		return
+	}
+	if old := atomic.SwapUint64(&v.cache.trackHeight, height); height < old {
+		v.invalidateCache(height)
+	}
+}
+
+// invalidateCache drops the accumulations which include height. The
+// generation is bumped before the removal, so a load racing with it is either
+// removed or not added.
+func (v *Listener) invalidateCache(height uint64) {
+	if v.cache == nil || height > atomic.LoadUint64(&v.cache.maxEndHeight) {
+		return
+	}
+	atomic.AddUint64(&v.cache.generation, 1)
+	v.cache.lru.RemoveIf(func(key accumulateKey) bool {
+		return key.endHeight >= height
+	})
+}
+
+// loadAccumulated answers an accumulation from the cache when it is immutable
+func (v *Listener) loadAccumulated(kind, address string, endHeight uint64,
+	load func(string, uint64) (*big.Int, error)) (*big.Int, error) {
+	c := v.cache
+	if c == nil || endHeight+c.confirmations >= atomic.LoadUint64(&c.trackHeight) {
+		return load(address, endHeight)
+	}
+	key := accumulateKey{kind: kind, address: address, endHeight: endHeight}
+	if amount, ok := c.lru.Get(key); ok {
+		return new(big.Int).Set(amount), nil
+	}
+	for max := atomic.LoadUint64(&c.maxEndHeight); max < endHeight; max = atomic.LoadUint64(&c.maxEndHeight) {
+		if atomic.CompareAndSwapUint64(&c.maxEndHeight, max, endHeight) {
+			break
+		}
+	}
+	generation := atomic.LoadUint64(&c.generation)
+	amount, err := load(address, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	// skip values which may predate an invalidation
+	c.lru.AddIf(key, new(big.Int).Set(amount), func() bool {
+		return atomic.LoadUint64(&c.generation) == generation
+	})
+	return amount, nil
+}
+
+func (v *Listener) accumulatedRewards(ctx context.Context, address string, endHeight uint64) (*big.Int, error) {
+	return v.loadAccumulated(ACCUMULATE_REWARDS, address, endHeight, v.db.WithContext(ctx).LoadAccumulateRewards)
+}
+
+func (v *Listener) accumulatedGasFee(ctx context.Context, address string, endHeight uint64) (*big.Int, error) {
+	return v.loadAccumulated(ACCUMULATE_GASFEE, address, endHeight, v.db.WithContext(ctx).LoadAccumulateGasFee)
+}
@@ -0,0 +1,134 @@
+package listener
+
+import (
+	"math/big"
+	"testing"
+)
+
+// countingLoad loads the end height as the amount and counts the loads
+type countingLoad struct {
+	loads int
+}
+
+func (l *countingLoad) load(address string, endHeight uint64) (*big.Int, error) {
+	l.loads++
+	return new(big.Int).SetUint64(endHeight), nil
+}
+
+func newCachedListener(confirmations, trackHeight uint64) *Listener {
+	v := new(Listener)
+	v.EnableCache(10, confirmations)
+	v.setTrackHeight(trackHeight)
+	return v
+}
+
+func TestCacheConfirmations(t *testing.T) {
+	v := newCachedListener(5, 100)
+	tests := []struct {
+		endHeight uint64
+		cached    bool
+	}{
+		{90, true},
+		{94, true},
+		{95, false}, // within the confirmations of the track height
+		{99, false},
+		{120, false},
+	}
+	for _, test := range tests {
+		l := new(countingLoad)
+		for i := 0; i < 2; i++ {
+			amount, err := v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", test.endHeight, l.load)
+			if err != nil || amount.Uint64() != test.endHeight {
+				t.Fatalf("end height %d: %v, %v", test.endHeight, amount, err)
+			}
+		}
+		if want := map[bool]int{true: 1, false: 2}[test.cached]; l.loads != want {
+			t.Errorf("end height %d loaded %d times, want %d", test.endHeight, l.loads, want)
+		}
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	v := newCachedListener(0, 100)
+	l := new(countingLoad)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 50, l.load)
+	v.loadAccumulated(ACCUMULATE_GASFEE, "0xa", 50, l.load)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xb", 50, l.load)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 51, l.load)
+	if l.loads != 4 {
+		t.Errorf("loaded %d times, kind, address and end height each key the cache", l.loads)
+	}
+}
+
+func TestCacheCopies(t *testing.T) {
+	v := newCachedListener(0, 100)
+	l := new(countingLoad)
+	amount, _ := v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 50, l.load)
+	amount.SetInt64(-1)
+	if cached, _ := v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 50, l.load); cached.Int64() != 50 {
+		t.Errorf("cached amount changed to %s by the caller", cached)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	v := newCachedListener(0, 100)
+	l := new(countingLoad)
+	for _, endHeight := range []uint64{40, 60, 80} {
+		v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", endHeight, l.load)
+	}
+
+	// moving forward keeps every entry
+	v.setTrackHeight(110)
+	if stats := v.cache.lru.Stats(); stats.Size != 3 {
+		t.Fatalf("size %d after moving forward, want 3", stats.Size)
+	}
+
+	// rescanning from 60 drops the accumulations including it
+	v.setTrackHeight(60)
+	stats := v.cache.lru.Stats()
+	if stats.Size != 1 || stats.Invalidations != 2 {
+		t.Fatalf("stats %+v after rescanning from 60, want 1 entry and 2 invalidations", stats)
+	}
+	loads := l.loads
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 40, l.load)
+	if l.loads != loads {
+		t.Error("accumulation below the rescan loaded again")
+	}
+	// 60 and 80 are not immutable until the listener passes them again
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 60, l.load)
+	v.setTrackHeight(100)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 80, l.load)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 80, l.load)
+	if l.loads != loads+2 {
+		t.Errorf("loaded %d times after the rescan, want 2", l.loads-loads)
+	}
+}
+
+func TestCacheInvalidationAboveCached(t *testing.T) {
+	v := newCachedListener(0, 100)
+	l := new(countingLoad)
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 40, l.load)
+	generation := v.cache.generation
+	// nothing was cached at or above 90
+	v.setTrackHeight(90)
+	if v.cache.generation != generation {
+		t.Error("rescan above every cached end height bumped the generation")
+	}
+	if stats := v.cache.lru.Stats(); stats.Size != 1 {
+		t.Errorf("size %d, want 1", stats.Size)
+	}
+}
+
+func TestCacheSkipsStaleLoad(t *testing.T) {
+	v := newCachedListener(0, 100)
+	// history is rescanned while the accumulation loads
+	load := func(address string, endHeight uint64) (*big.Int, error) {
+		v.setTrackHeight(30)
+		v.setTrackHeight(100)
+		return big.NewInt(1), nil
+	}
+	v.loadAccumulated(ACCUMULATE_REWARDS, "0xa", 50, load)
+	if stats := v.cache.lru.Stats(); stats.Size != 0 {
+		t.Errorf("size %d, an accumulation loaded across an invalidation was cached", stats.Size)
+	}
+}
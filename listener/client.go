@@ -20,11 +20,12 @@
 package listener
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"strconv"
-	"strings"
 )
 
 type heightReq struct {
@@ -40,7 +41,11 @@ type heightRep struct {
 	ID      uint   `json:"id"`
 }
 
-func GetCurrentHeight(url string) (height uint64, err error) {
+func GetCurrentHeight(ctx context.Context, url string) (height uint64, err error) {
+	ctx, done := startRpc(ctx, "eth_blockNumber")
+	defer func() {
+		done(err)
+	}()
 	req := &heightReq{
 		JSONRPC: "2.0",
 		Method:  "eth_blockNumber",
@@ -49,7 +54,7 @@ func GetCurrentHeight(url string) (height uint64, err error) {
 	}
 	data, _ := json.Marshal(req)
 
-	body, err := jsonRequest(url, data)
+	body, err := jsonRequest(ctx, url, data)
 	if err != nil {
 		return
 	}
@@ -68,8 +73,13 @@ func GetCurrentHeight(url string) (height uint64, err error) {
 	return
 }
 
-func jsonRequest(url string, data []byte) (result []byte, err error) {
-	resp, err := http.Post(url, "application/json", strings.NewReader(string(data)))
+func jsonRequest(ctx context.Context, url string, data []byte) (result []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,60 @@
+package listener
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/contracts/native/governance/node_manager"
+	"github.com/polynetwork/distribute-check/store"
+	"github.com/polynetwork/distribute-check/store/models"
+	"github.com/polynetwork/distribute-check/utils"
+)
+
+// DUST_DECIMALS are the decimals of TokenDecimal dust is scaled by
+const DUST_DECIMALS = 18
+
+// formatDust formats dust as a decimal wei amount
+func formatDust(amount *big.Int) string {
+	return utils.ToStringByPrecise(amount, DUST_DECIMALS)
+}
+
+// The dust functions return what the matching calc function truncates, in
+// wei scaled by TokenDecimal.
+
+// splitDust is the remainder of calcValidatorRewards
+func splitDust(totalRewards, validatorRewards *big.Int, validatorNum uint64) *big.Int {
+	dust := new(big.Int).Mul(validatorRewards, new(big.Int).SetUint64(validatorNum))
+	dust.Sub(totalRewards, dust)
+	return dust.Mul(dust, node_manager.TokenDecimal)
+}
+
+// perTokenDust is the remainder of calcRewardsPerToken, the rewards per
+// token lose remainder / totalStake on each of the totalStake / TokenDecimal
+// tokens.
+func perTokenDust(stakeRewards, totalStake *big.Int) *big.Int {
+	return new(big.Int).Mod(new(big.Int).Mul(stakeRewards, node_manager.TokenDecimal), totalStake)
+}
+
+// stakeDust is the remainder of calcStakeRewards
+func stakeDust(stake, rewardsPerToken *big.Int) *big.Int {
+	return new(big.Int).Mod(new(big.Int).Mul(stake, rewardsPerToken), node_manager.TokenDecimal)
+}
+
+// saveDust records the dust of a stage of CalcRewards
+func saveDust(db *store.Client, height uint64, validator, stage string, amount *big.Int) error {
+	err := db.SaveRewardDust(&models.RewardDust{Height: height, Validator: validator, Stage: stage, Amount: models.NewBigInt(amount)})
+	if err != nil {
+		return fmt.Errorf("saveDust, db.SaveRewardDust error: %s", err)
+	}
+	observeDust(stage, amount)
+	return nil
+}
+
+// sumDust adds up dust amounts, it returns false if there are none
+func sumDust(dust []models.RewardDust) (*big.Int, bool) {
+	total := new(big.Int)
+	for _, d := range dust {
+		total.Add(total, &d.Amount.Int)
+	}
+	return total, len(dust) != 0
+}
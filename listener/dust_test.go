@@ -0,0 +1,259 @@
+package listener
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/contracts/native/governance/node_manager"
+	"github.com/polynetwork/distribute-check/store/models"
+)
+
+// amount parses an exact decimal amount like 1.25e18
+func amount(s string) *big.Int {
+	f, ok := new(big.Float).SetPrec(256).SetString(s)
+	if !ok {
+		panic("invalid amount " + s)
+	}
+	i, accuracy := f.Int(nil)
+	if accuracy != big.Exact {
+		panic("inexact amount " + s)
+	}
+	return i
+}
+
+func TestSplitDust(t *testing.T) {
+	tests := []struct {
+		name             string
+		totalRewards     string
+		validatorRewards string
+		validatorNum     uint64
+		dust             string
+	}{
+		{"even split", "999", "333", 3, "0"},
+		{"remainder", "1001", "333", 3, "2e18"},
+		{"one validator", "7", "7", 1, "0"},
+		{"less than a wei each", "2", "0", 3, "2e18"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rewards := calcValidatorRewards(amount(test.totalRewards), test.validatorNum); rewards.Cmp(amount(test.validatorRewards)) != 0 {
+				t.Fatalf("validator rewards %s, want %s", rewards, test.validatorRewards)
+			}
+			dust := splitDust(amount(test.totalRewards), amount(test.validatorRewards), test.validatorNum)
+			if dust.Cmp(amount(test.dust)) != 0 {
+				t.Errorf("dust %s, want %s", dust, test.dust)
+			}
+		})
+	}
+}
+
+func TestPerTokenDust(t *testing.T) {
+	tests := []struct {
+		name            string
+		stakeRewards    string
+		totalStake      string
+		rewardsPerToken string
+		dust            string
+	}{
+		{"exact", "10", "2e18", "5", "0"},
+		{"remainder", "10", "3e18", "3", "1e18"},
+		{"stake below a token", "10", "3e17", "33", "1e17"},
+		{"no rewards", "0", "3e18", "0", "0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if perToken := calcRewardsPerToken(amount(test.stakeRewards), amount(test.totalStake)); perToken.Cmp(amount(test.rewardsPerToken)) != 0 {
+				t.Fatalf("rewards per token %s, want %s", perToken, test.rewardsPerToken)
+			}
+			if dust := perTokenDust(amount(test.stakeRewards), amount(test.totalStake)); dust.Cmp(amount(test.dust)) != 0 {
+				t.Errorf("dust %s, want %s", dust, test.dust)
+			}
+		})
+	}
+}
+
+func TestStakeDust(t *testing.T) {
+	tests := []struct {
+		name            string
+		stake           string
+		rewardsPerToken string
+		rewards         string
+		dust            string
+	}{
+		{"whole tokens", "2e18", "3", "6", "0"},
+		{"quarter token", "1.25e18", "3", "3", "7.5e17"},
+		{"three quarter token", "1.75e18", "3", "5", "2.5e17"},
+		{"below a wei", "1e17", "3", "0", "3e17"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rewards := calcStakeRewards(amount(test.stake), amount(test.rewardsPerToken)); rewards.Cmp(amount(test.rewards)) != 0 {
+				t.Fatalf("rewards %s, want %s", rewards, test.rewards)
+			}
+			if dust := stakeDust(amount(test.stake), amount(test.rewardsPerToken)); dust.Cmp(amount(test.dust)) != 0 {
+				t.Errorf("dust %s, want %s", dust, test.dust)
+			}
+		})
+	}
+}
+
+type testStake struct {
+	address string
+	amount  string
+}
+
+// testValidator is a validator of a CalcRewards case, its total stake is the
+// sum of its stakes
+type testValidator struct {
+	address      string
+	stakeAddress string
+	commission   int64 // out of PercentDecimal
+	stakes       []testStake
+}
+
+type calcResult struct {
+	credited  *big.Int
+	carryOver *big.Int
+	dust      *big.Int // recorded, scaled by TokenDecimal
+	calcs     []models.ValidatorRewardsCalc
+	details   []models.RewardDetail
+}
+
+// calcRewards computes what CalcRewards credits and records for a block
+// without the database.
+func calcRewards(totalRewards *big.Int, validators []testValidator) *calcResult {
+	r := &calcResult{credited: new(big.Int), carryOver: new(big.Int), dust: new(big.Int)}
+	if len(validators) == 0 {
+		r.carryOver.Set(totalRewards)
+		return r
+	}
+	validatorRewards := calcValidatorRewards(totalRewards, uint64(len(validators)))
+	r.dust.Add(r.dust, splitDust(totalRewards, validatorRewards, uint64(len(validators))))
+	for _, validator := range validators {
+		totalStake := new(big.Int)
+		for _, stake := range validator.stakes {
+			totalStake.Add(totalStake, amount(stake.amount))
+		}
+		commission, stakeRewards := calcCommission(validatorRewards, big.NewInt(validator.commission))
+		rewardsPerToken := calcRewardsPerToken(stakeRewards, totalStake)
+		r.calcs = append(r.calcs, models.ValidatorRewardsCalc{
+			ConsensusAddress: validator.address,
+			StakeAddress:     validator.stakeAddress,
+			TotalStake:       models.NewBigInt(totalStake),
+		})
+		for _, stake := range validator.stakes {
+			rewards := calcStakeRewards(amount(stake.amount), rewardsPerToken)
+			r.dust.Add(r.dust, stakeDust(amount(stake.amount), rewardsPerToken))
+			r.details = append(r.details, models.RewardDetail{Address: stake.address, Validator: validator.address,
+				Kind: models.RewardKindStake, Amount: models.NewBigInt(rewards)})
+			r.credited.Add(r.credited, rewards)
+			// the commission is credited with the stake of the stake address
+			if stake.address == validator.stakeAddress {
+				r.details = append(r.details, models.RewardDetail{Address: stake.address, Validator: validator.address,
+					Kind: models.RewardKindCommission, Amount: models.NewBigInt(commission)})
+				r.credited.Add(r.credited, commission)
+			}
+		}
+		r.dust.Add(r.dust, perTokenDust(stakeRewards, totalStake))
+	}
+	return r
+}
+
+var calcCases = []struct {
+	name         string
+	totalRewards string
+	validators   []testValidator
+	credited     string
+	dust         string
+	bound        string // of dustBound
+	conserved    bool   // credited, carried over and dust add up to the total rewards
+}{
+	{
+		name:         "no validators",
+		totalRewards: "1001",
+		credited:     "0",
+		dust:         "0",
+		bound:        "0",
+		conserved:    true,
+	},
+	{
+		name:         "one staker",
+		totalRewards: "1000",
+		validators: []testValidator{
+			{address: "v1", stakeAddress: "a", commission: 1000, stakes: []testStake{{"a", "3e18"}}},
+		},
+		// commission 100, 300 per token on 3 tokens
+		credited:  "1000",
+		dust:      "0",
+		bound:     "5e18",
+		conserved: true,
+	},
+	{
+		name:         "stakers with remainders",
+		totalRewards: "21",
+		validators: []testValidator{
+			// 10 each, 1 wei split dust. 3 per token on 3 tokens, 1 wei per
+			// token dust, stakes of 3.75 and 5.25 truncated to 3 and 5
+			{address: "v1", stakeAddress: "a", stakes: []testStake{{"a", "1.25e18"}, {"b", "1.75e18"}}},
+			// commission 2, 2 per token on 3 tokens, 2 wei per token dust
+			{address: "v2", stakeAddress: "c", commission: 2500, stakes: []testStake{{"c", "1e18"}, {"d", "1e18"}, {"e", "1e18"}}},
+		},
+		credited:  "16",
+		dust:      "5e18",
+		bound:     "14e18",
+		conserved: true,
+	},
+	{
+		name:         "stake address without stake",
+		totalRewards: "10",
+		validators: []testValidator{
+			// the commission of 5 is never credited
+			{address: "v1", stakeAddress: "a", commission: 5000, stakes: []testStake{{"b", "1e18"}}},
+		},
+		credited:  "5",
+		dust:      "0",
+		bound:     "3e18",
+		conserved: false,
+	},
+}
+
+func TestCalcRewardsDust(t *testing.T) {
+	for _, test := range calcCases {
+		t.Run(test.name, func(t *testing.T) {
+			r := calcRewards(amount(test.totalRewards), test.validators)
+			if r.credited.Cmp(amount(test.credited)) != 0 {
+				t.Errorf("credited %s, want %s", r.credited, test.credited)
+			}
+			if r.dust.Cmp(amount(test.dust)) != 0 {
+				t.Errorf("dust %s, want %s", formatDust(r.dust), formatDust(amount(test.dust)))
+			}
+			input := new(big.Int).Mul(amount(test.totalRewards), node_manager.TokenDecimal)
+			output := new(big.Int).Add(r.credited, r.carryOver)
+			output.Mul(output, node_manager.TokenDecimal)
+			if conserved := input.Cmp(output.Add(output, r.dust)) == 0; conserved != test.conserved {
+				t.Errorf("conserved %v, input %s, credited %s, carried over %s, dust %s",
+					conserved, test.totalRewards, r.credited, r.carryOver, formatDust(r.dust))
+			}
+		})
+	}
+}
+
+// TestDustBound checks the fallback of checkConservation for heights
+// calculated before dust was recorded.
+func TestDustBound(t *testing.T) {
+	for _, test := range calcCases {
+		t.Run(test.name, func(t *testing.T) {
+			r := calcRewards(amount(test.totalRewards), test.validators)
+			bound := dustBound(uint64(len(test.validators)), r.calcs, r.details)
+			if bound.Cmp(amount(test.bound)) != 0 {
+				t.Fatalf("bound %s, want %s", formatDust(bound), formatDust(amount(test.bound)))
+			}
+			dust := new(big.Int).Sub(amount(test.totalRewards), r.credited)
+			dust.Sub(dust, r.carryOver)
+			dust.Mul(dust, node_manager.TokenDecimal)
+			if within := dust.Sign() >= 0 && dust.Cmp(bound) <= 0; within != test.conserved {
+				t.Errorf("dust %s within [0, %s] is %v", formatDust(dust), formatDust(bound), within)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// progress is where the listener stands, updated by Listen
+type progress struct {
+	head       uint64
+	handled    uint64
+	progressAt int64 // unix nano of the last block handled or caught up poll
+}
+
+// recordHeights records the chain head and the last handled height
+func (v *Listener) recordHeights(head, handled uint64) {
+	observeHeights(head, handled)
+	atomic.StoreUint64(&v.progress.head, head)
+	if old := atomic.SwapUint64(&v.progress.handled, handled); handled > old || handled >= head {
+		atomic.StoreInt64(&v.progress.progressAt, time.Now().UnixNano())
+	}
+}
+
+// CheckLag fails while the listener is more than maxLag blocks behind the
+// head, or before it polled the head.
+func (v *Listener) CheckLag(maxLag uint64) func(context.Context) error {
+	return func(context.Context) error {
+		head, handled := atomic.LoadUint64(&v.progress.head), atomic.LoadUint64(&v.progress.handled)
+		if head == 0 {
+			return fmt.Errorf("chain head unknown")
+		}
+		if head > handled && head-handled > maxLag {
+			return fmt.Errorf("handled height %d is %d blocks behind head %d, max %d", handled, head-handled, head, maxLag)
+		}
+		return nil
+	}
+}
+
+// CheckProgress fails if no block was handled within maxAge, unless the
+// listener was caught up with the head meanwhile.
+func (v *Listener) CheckProgress(maxAge time.Duration) func(context.Context) error {
+	return func(context.Context) error {
+		at := atomic.LoadInt64(&v.progress.progressAt)
+		if at == 0 {
+			return fmt.Errorf("no block handled yet")
+		}
+		if age := time.Since(time.Unix(0, at)); age > maxAge {
+			return fmt.Errorf("no block handled for %s, max %s", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
+
+// CheckInvariants fails once the listener halted on an invariant violation
+func (v *Listener) CheckInvariants(context.Context) error {
+	if height := v.haltedAt(); height != 0 {
+		return fmt.Errorf("halted on invariant violation at height %d", height)
+	}
+	return nil
+}
+
+// CheckRpc fails if the zion rpc does not answer the block number.
+func (v *Listener) CheckRpc(ctx context.Context) error {
+	rpcCtx, done := startRpc(ctx, "eth_blockNumber")
+	_, err := v.client.BlockNumber(rpcCtx)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("CheckRpc, v.client.BlockNumber error: %s", err)
+	}
+	return nil
+}
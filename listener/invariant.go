@@ -0,0 +1,286 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/contracts/native/go_abi/node_manager_abi"
+	"github.com/ethereum/go-ethereum/contracts/native/governance/node_manager"
+	"github.com/polynetwork/distribute-check/events"
+	"github.com/polynetwork/distribute-check/store"
+	"github.com/polynetwork/distribute-check/store/models"
+	"github.com/polynetwork/distribute-check/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	INVARIANT_CONSERVATION = "conservation"
+	INVARIANT_STAKE_SUM    = "stakesum"
+)
+
+// Invariant is a property of the state which holds after every block, Check
+// returns the violations found after the block at height. An invariant with
+// Methods is only checked after blocks with txs of one of them.
+type Invariant struct {
+	Name    string
+	Methods []string
+	Check   func(ctx context.Context, db *store.Client, height uint64) ([]*events.Mismatch, error)
+}
+
+// DefaultInvariants are conservation of the rewards and the stake of each
+// validator adding up.
+func DefaultInvariants() []Invariant {
+	return []Invariant{
+		{Name: INVARIANT_CONSERVATION, Check: checkConservation},
+		{
+			Name:    INVARIANT_STAKE_SUM,
+			Methods: []string{node_manager_abi.MethodCreateValidator, node_manager_abi.MethodStake, node_manager_abi.MethodUnStake},
+			Check:   checkStakeSum,
+		},
+	}
+}
+
+// checked returns whether the invariant is checked after a block with the
+// txs counted by methods.
+func (invariant Invariant) checked(methods map[string]int) bool {
+	if len(invariant.Methods) == 0 {
+		return true
+	}
+	for _, method := range invariant.Methods {
+		if methods[method] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type invariantEngine struct {
+	invariants []Invariant
+	halt       bool
+	haltedAt   uint64 // height of the violation halting the listener, 0 if running
+	// violations found after the last block, a persisting violation is
+	// recorded again only once its values change
+	active map[string]string
+}
+
+// EnableInvariants checks the invariants after each block. If halt is set the
+// listener stops handling blocks after the first violating one, also after a
+// restart, until the violation is acknowledged in the database.
+func (v *Listener) EnableInvariants(halt bool, invariants ...Invariant) {
+	v.invariants = &invariantEngine{invariants: invariants, halt: halt, active: make(map[string]string)}
+}
+
+// checkInvariants records and publishes the violations found after the
+// block at height, it returns whether the listener has to halt.
+func (v *Listener) checkInvariants(ctx context.Context, height uint64, methods map[string]int) bool {
+	engine := v.invariants
+	if engine == nil {
+		return false
+	}
+	ctx, span := tracing.Start(ctx, "Listener.CheckInvariants", attribute.Int64("height", int64(height)))
+	defer span.End()
+	db := v.db.WithContext(ctx)
+	violated := false
+	active := make(map[string]string)
+	for _, invariant := range engine.invariants {
+		if !invariant.checked(methods) {
+			keepActive(active, engine.active, invariant.Name)
+			continue
+		}
+		mismatches, err := invariant.Check(ctx, db, height)
+		if err != nil {
+			// an invariant which can not be checked is not violated, its
+			// violations are still not recorded again once checked
+			logger.With("height", height, "invariant", invariant.Name).Errorf("checkInvariants, check error: %s", err)
+			tracing.SetError(span, err)
+			keepActive(active, engine.active, invariant.Name)
+			continue
+		}
+		for _, mismatch := range mismatches {
+			mismatch.Height = height
+			mismatch.Check = invariant.Name
+			violated = true
+			key, values := invariant.Name+"/"+mismatch.Address, mismatch.Expected+"/"+mismatch.Actual
+			active[key] = values
+			if engine.active[key] == values {
+				continue
+			}
+			invariantViolations.WithLabelValues(invariant.Name).Inc()
+			v.publishMismatch(ctx, mismatch, engine.halt)
+		}
+	}
+	engine.active = active
+	if violated && engine.halt {
+		atomic.StoreUint64(&engine.haltedAt, height)
+		return true
+	}
+	return false
+}
+
+// keepActive copies the violations of an invariant which was not checked
+// from the previous block.
+func keepActive(active, previous map[string]string, invariant string) {
+	for key, values := range previous {
+		if strings.HasPrefix(key, invariant+"/") {
+			active[key] = values
+		}
+	}
+}
+
+// loadHalt restores the halt on a violation which was not acknowledged, the
+// track height is already past its block.
+func (v *Listener) loadHalt() error {
+	engine := v.invariants
+	if engine == nil || !engine.halt {
+		return nil
+	}
+	violation, err := v.db.LoadHaltingViolation()
+	if err != nil {
+		return fmt.Errorf("loadHalt, db.LoadHaltingViolation error: %s", err)
+	}
+	if violation != nil {
+		atomic.StoreUint64(&engine.haltedAt, violation.Height)
+	}
+	return nil
+}
+
+// haltedAt returns the height of the violation halting the listener, 0 if
+// it was not halted.
+func (v *Listener) haltedAt() uint64 {
+	if v.invariants == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&v.invariants.haltedAt)
+}
+
+// checkConservation checks that the rewards of a block, its gas and the
+// rewards carried over to it are all credited, carried over again or
+// recorded as dust. Heights calculated before dust was recorded only have to
+// stay within the dust the integer divisions of CalcRewards may truncate.
+func checkConservation(ctx context.Context, db *store.Client, height uint64) ([]*events.Mismatch, error) {
+	calc, err := db.FindRewardsCalc(height)
+	if err != nil {
+		return nil, fmt.Errorf("checkConservation, db.FindRewardsCalc error: %s", err)
+	}
+	if calc == nil {
+		return nil, nil
+	}
+	carryOver, err := db.LoadAccumulatedRewards()
+	if err != nil {
+		return nil, fmt.Errorf("checkConservation, db.LoadAccumulatedRewards error: %s", err)
+	}
+	details, err := db.LoadRewardDetailsAtHeight(height)
+	if err != nil {
+		return nil, fmt.Errorf("checkConservation, db.LoadRewardDetailsAtHeight error: %s", err)
+	}
+	recorded, err := db.LoadRewardDust(height, "")
+	if err != nil {
+		return nil, fmt.Errorf("checkConservation, db.LoadRewardDust error: %s", err)
+	}
+
+	input := new(big.Int).Add(&calc.BlockRewards.Int, &calc.TotalGas.Int)
+	input.Add(input, &calc.AccumulatedRewards.Int)
+	credited := new(big.Int)
+	for _, detail := range details {
+		credited.Add(credited, &detail.Amount.Int)
+	}
+	output := new(big.Int).Add(credited, carryOver)
+	// scaled like the recorded dust
+	dust := new(big.Int).Sub(input, output)
+	dust.Mul(dust, node_manager.TokenDecimal)
+	detail := fmt.Sprintf("block rewards %s + gas %s + carried over %s, credited %s + carried over %s, dust %s",
+		calc.BlockRewards, calc.TotalGas, calc.AccumulatedRewards, credited, carryOver, formatDust(dust))
+
+	if total, ok := sumDust(recorded); ok {
+		if dust.Cmp(total) == 0 {
+			return nil, nil
+		}
+		accounted := new(big.Int).Mul(output, node_manager.TokenDecimal)
+		accounted.Add(accounted, total)
+		return []*events.Mismatch{{
+			Expected: input.String(),
+			Actual:   formatDust(accounted),
+			Detail:   detail + ", recorded dust " + formatDust(total),
+		}}, nil
+	}
+
+	validatorCalcs, err := db.LoadValidatorRewardsCalcsAtHeight(height)
+	if err != nil {
+		return nil, fmt.Errorf("checkConservation, db.LoadValidatorRewardsCalcsAtHeight error: %s", err)
+	}
+	bound := dustBound(calc.ValidatorNum, validatorCalcs, details)
+	if dust.Sign() >= 0 && dust.Cmp(bound) <= 0 {
+		return nil, nil
+	}
+	return []*events.Mismatch{{
+		Expected: input.String(),
+		Actual:   output.String(),
+		Detail:   fmt.Sprintf("%s outside [0, %s]", detail, formatDust(bound)),
+	}}, nil
+}
+
+// dustBound is the most CalcRewards may truncate, scaled like the recorded
+// dust: less than a wei per validator splitting the block, and for each
+// validator less than a wei per staker plus what truncating the rewards per
+// token loses over its stake.
+func dustBound(validatorNum uint64, validatorCalcs []models.ValidatorRewardsCalc, details []models.RewardDetail) *big.Int {
+	bound := new(big.Int)
+	if validatorNum > 0 {
+		bound.SetUint64(validatorNum - 1)
+	}
+	stakers := make(map[string]int64)
+	for _, detail := range details {
+		if detail.Kind == models.RewardKindStake {
+			stakers[detail.Validator]++
+		}
+	}
+	for _, calc := range validatorCalcs {
+		perToken := new(big.Int).Div(&calc.TotalStake.Int, node_manager.TokenDecimal)
+		bound.Add(bound, perToken.Add(perToken, big.NewInt(stakers[calc.ConsensusAddress]+1)))
+	}
+	return bound.Mul(bound, node_manager.TokenDecimal)
+}
+
+// checkStakeSum checks that the stakes of the stakers of each validator add
+// up to its total stake.
+func checkStakeSum(ctx context.Context, db *store.Client, height uint64) ([]*events.Mismatch, error) {
+	validators, err := db.LoadValidators()
+	if err != nil {
+		return nil, fmt.Errorf("checkStakeSum, db.LoadValidators error: %s", err)
+	}
+	stakeInfos, err := db.LoadAllStakeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("checkStakeSum, db.LoadAllStakeInfo error: %s", err)
+	}
+	sums := make(map[string]*big.Int, len(validators))
+	stakers := make(map[string]int, len(validators))
+	for _, stakeInfo := range stakeInfos {
+		sum, ok := sums[stakeInfo.ConsensusAddress]
+		if !ok {
+			sum = new(big.Int)
+			sums[stakeInfo.ConsensusAddress] = sum
+		}
+		sum.Add(sum, &stakeInfo.Amount.Int)
+		stakers[stakeInfo.ConsensusAddress]++
+	}
+	mismatches := make([]*events.Mismatch, 0)
+	for _, validator := range validators {
+		sum, ok := sums[validator.ConsensusAddress]
+		if !ok {
+			sum = new(big.Int)
+		}
+		if sum.Cmp(&validator.TotalStake.Int) == 0 {
+			continue
+		}
+		mismatches = append(mismatches, &events.Mismatch{
+			Address:  validator.ConsensusAddress,
+			Expected: validator.TotalStake.String(),
+			Actual:   sum.String(),
+			Detail:   fmt.Sprintf("stakes of %d stakers differ from the total stake", stakers[validator.ConsensusAddress]),
+		})
+	}
+	return mismatches, nil
+}
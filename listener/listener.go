@@ -29,27 +29,39 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/polynetwork/distribute-check/events"
 	"github.com/polynetwork/distribute-check/log"
 	"github.com/polynetwork/distribute-check/store"
 	"github.com/polynetwork/distribute-check/store/models"
+	"github.com/polynetwork/distribute-check/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"math/big"
-	"os"
 	"strings"
 	"time"
 )
 
 var nmAbi abi.ABI
 
+var (
+	logger    = log.WithComponent(log.COMPONENT_LISTENER)
+	rpcLogger = log.WithComponent(log.COMPONENT_RPC)
+)
+
 type Listener struct {
-	rpc      string
-	client   *ethclient.Client
-	db       *store.Client
-	contract *node_manager_abi.INodeManager
-	chainId  *big.Int
+	rpc        string
+	client     *ethclient.Client
+	db         *store.Client
+	bus        *events.Bus
+	contract   *node_manager_abi.INodeManager
+	chainId    *big.Int
+	cache      *accumulateCache
+	progress   progress
+	invariants *invariantEngine
+	alerter    Alerter
 }
 
-func New(rpc string, db *store.Client) *Listener {
-	return &Listener{rpc: rpc, db: db}
+func New(rpc string, db *store.Client, bus *events.Bus) *Listener {
+	return &Listener{rpc: rpc, db: db, bus: bus}
 }
 
 func (v *Listener) Init() (err error) {
@@ -86,107 +98,186 @@ func (v *Listener) Init() (err error) {
 	return nil
 }
 
-func (v *Listener) Listen(ctx context.Context) {
+// Listen handles blocks until ctx is done, the block being handled is
+// finished before it returns.
+func (v *Listener) Listen(ctx context.Context) error {
 	trackHeight, err := v.db.LoadTrackHeight()
 	if err != nil {
-		log.Fatalf("v.db.LoadTrackHeight error: %s", err)
-		os.Exit(1)
+		return fmt.Errorf("Listen, v.db.LoadTrackHeight error: %s", err)
+	}
+	v.setTrackHeight(trackHeight)
+	err = v.loadHalt()
+	if err != nil {
+		return fmt.Errorf("Listen, v.loadHalt error: %s", err)
 	}
 	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
 	for {
+		if height := v.haltedAt(); height != 0 {
+			// kept running and unready, a restart resumes after the
+			// violating block only once the violation is acknowledged
+			logger.With("height", height).Error("listener halted on invariant violation")
+			<-ctx.Done()
+			return nil
+		}
 		select {
 		case <-ticker.C:
-			height, err := GetCurrentHeight(v.rpc)
-			if err != nil {
-				log.Errorf("GetCurrentHeight failed:%v", err)
-				continue
-			}
-			log.Infof("current zion height:%d", height)
-			if height < trackHeight {
-				continue
-			}
+			trackHeight = v.poll(ctx, trackHeight)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
 
-			for trackHeight <= height {
-				select {
-				case <-ctx.Done():
-					log.Info("quiting from signal...")
-					return
-				default:
-				}
-				log.Infof("handling zion height:%d", trackHeight)
-				err = v.ScanAndExecBlock(trackHeight)
-				if err != nil {
-					log.Errorf("ScanAndExecBlock failed:%v", err)
-					sleep()
-					continue
-				}
+// poll handles the blocks from trackHeight up to the chain head, it returns
+// the next height to handle.
+func (v *Listener) poll(ctx context.Context, trackHeight uint64) uint64 {
+	height, err := GetCurrentHeight(ctx, v.rpc)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.With("method", "eth_blockNumber").Errorf("GetCurrentHeight failed: %s", err)
+		}
+		return trackHeight
+	}
+	logger.With("height", height).Info("current zion height")
+	v.recordHeights(height, trackHeight-1)
+	if height < trackHeight {
+		return trackHeight
+	}
 
-				trackHeight = trackHeight + 1
-				err = v.db.SaveTrackHeight(trackHeight)
-				if err != nil {
-					log.Errorf("v.db.SaveTrackHeight failed:%v", err)
-				}
+	attempts := 0
+	for trackHeight <= height {
+		// ctx is only checked between blocks, see handleBlock
+		if ctx.Err() != nil {
+			return trackHeight
+		}
+		logger.With("height", trackHeight).Info("handling zion height")
+		halt, err := v.handleBlock(trackHeight)
+		if err != nil {
+			attempts++
+			logger.With("height", trackHeight, "attempts", attempts).Errorf("ScanAndExecBlock failed: %s", err)
+			failed := &events.BlockFailed{Height: trackHeight, Attempts: attempts, Error: err.Error()}
+			v.bus.Publish(events.TOPIC_BLOCK_FAILED, failed)
+			if v.alerter != nil {
+				v.alerter.BlockFailed(context.Background(), failed)
 			}
+			sleep(ctx)
+			continue
+		}
+		if attempts > 0 && v.alerter != nil {
+			v.alerter.BlockProcessed(context.Background(), trackHeight)
+		}
+		attempts = 0
 
-		case <-ctx.Done():
-			log.Info("quiting from signal...")
-			return
+		blocksProcessed.Inc()
+		v.recordHeights(height, trackHeight)
+		trackHeight = trackHeight + 1
+		v.setTrackHeight(trackHeight)
+		if halt {
+			// the violating block is handled, the next one waits for the
+			// violation to be acknowledged
+			return trackHeight
 		}
 	}
+	return trackHeight
 }
 
-func (v *Listener) ScanAndExecBlock(height uint64) error {
+// handleBlock executes and checks the block at height in a root span of its
+// own. The txs of a block are not applied in one db transaction, so the work
+// is detached from the cancellation of the listener: a block stopped half way
+// would be counted twice once handled again. For the same reason the track
+// height is saved past the block before its invariants are checked, a halt
+// on a violation never handles the block again.
+func (v *Listener) handleBlock(height uint64) (halt bool, err error) {
+	ctx, span := tracing.Start(context.Background(), "Listener.HandleBlock", attribute.Int64("height", int64(height)))
+	defer span.End()
+	methods, err := v.ScanAndExecBlock(ctx, height)
+	if err != nil {
+		tracing.SetError(span, err)
+		return false, err
+	}
+	err = v.db.WithContext(ctx).SaveTrackHeight(height + 1)
+	if err != nil {
+		logger.With("height", height+1).Errorf("db.SaveTrackHeight failed: %s", err)
+	}
+	return v.checkInvariants(ctx, height, methods), nil
+}
+
+// ScanAndExecBlock applies the txs of the block at height, it returns the
+// number of txs of each method.
+func (v *Listener) ScanAndExecBlock(ctx context.Context, height uint64) (methods map[string]int, err error) {
+	ctx, span := tracing.Start(ctx, "Listener.ScanAndExecBlock", attribute.Int64("height", int64(height)))
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
 	client := v.client
-	ctx := context.Background()
+	db := v.db.WithContext(ctx)
+	// the block may be handled again, e.g. after a failure
+	v.invalidateCache(height)
 
 	// get block
-	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(height))
+	rpcCtx, done := startRpc(ctx, "BlockByNumber")
+	block, err := client.BlockByNumber(rpcCtx, new(big.Int).SetUint64(height))
+	done(err)
 	if err != nil {
-		return fmt.Errorf("ScanAndExecBlock, client.BlockByNumber error: %s", err)
+		return nil, fmt.Errorf("ScanAndExecBlock, client.BlockByNumber error: %s", err)
 	}
+	span.SetAttributes(attribute.Int("txs", len(block.Transactions())))
 	totalGas := new(big.Int)
+	endBlock := false
+	methods = make(map[string]int)
 	for _, tx := range block.Transactions() {
 		// parse tx data
 		data := tx.Data()
 		methodName, err := nmAbi.MethodById(tx.Data())
 		if err != nil {
-			log.Infof("ScanAndExecBlock, nmAbi.MethodById not found: %s", err)
+			logger.With("height", height, "tx", tx.Hash().Hex()).Infof("ScanAndExecBlock, nmAbi.MethodById not found: %s", err)
 			continue
 		}
+		methods[methodName.Name]++
 		from, err := types.Sender(types.LatestSignerForChainID(v.chainId), tx)
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, types.Sender error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, types.Sender error: %s", err)
 		}
+		logger.With("height", height, "tx", tx.Hash().Hex(), "method", methodName.Name, "address", from.Hex()).Debug("handling tx")
 		// get transaction by hash
-		transaction, _, err := client.TransactionByHash(ctx, tx.Hash())
+		rpcCtx, done = startRpc(ctx, "TransactionByHash")
+		transaction, _, err := client.TransactionByHash(rpcCtx, tx.Hash())
+		done(err)
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, client.TransactionByHash error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, client.TransactionByHash error: %s", err)
 		}
 		// get receipt by hash
-		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		rpcCtx, done = startRpc(ctx, "TransactionReceipt")
+		receipt, err := client.TransactionReceipt(rpcCtx, tx.Hash())
+		done(err)
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, client.TransactionReceipt error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, client.TransactionReceipt error: %s", err)
 		}
 		// accumulate gas
 		gas := new(big.Int).Mul(transaction.GasPrice(), new(big.Int).SetUint64(receipt.GasUsed))
-		err = v.db.SaveGasFee(&models.GasFee{Address: from.Hex(), Height: height, GasFee: models.NewBigInt(gas)})
+		err = db.SaveGasFee(&models.GasFee{Address: from.Hex(), Height: height, GasFee: models.NewBigInt(gas)})
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, v.db.SaveGasFee error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, db.SaveGasFee error: %s", err)
 		}
 		totalGas = new(big.Int).Add(totalGas, gas)
-		err = v.db.SaveTotalGas(&models.TotalGas{Height: height, TotalGas: models.NewBigInt(totalGas)})
+		err = db.SaveTotalGas(&models.TotalGas{Height: height, TotalGas: models.NewBigInt(totalGas)})
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, v.db.SaveTotalGas error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, db.SaveTotalGas error: %s", err)
 		}
 
 		// if success
 		if receipt.Status == 0 {
 			continue
 		}
+		if methodName.Name == node_manager_abi.MethodEndBlock {
+			endBlock = true
+		}
 		// if done
-		doneTx, err := v.db.LoadDoneTx(tx.Hash().Hex())
+		doneTx, err := db.LoadDoneTx(tx.Hash().Hex())
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, v.db.LoadDoneTx error: %s", err)
+			return nil, fmt.Errorf("ScanAndExecBlock, db.LoadDoneTx error: %s", err)
 		}
 		if len(doneTx) != 0 {
 			continue
@@ -199,11 +290,11 @@ func (v *Listener) ScanAndExecBlock(height uint64) error {
 			method, _ := nmAbi.Methods[methodName.Name]
 			args, err := method.Inputs.Unpack(data[4:])
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
 			}
 			err = method.Inputs.Copy(param, args)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
 			}
 			validator := &models.Validator{
 				StakeAddress:     from.Hex(),
@@ -212,13 +303,13 @@ func (v *Listener) ScanAndExecBlock(height uint64) error {
 				TotalStake:       models.NewBigInt(transaction.Value()),
 				SelfStake:        models.NewBigInt(transaction.Value()),
 			}
-			err = v.db.SaveValidator(validator)
+			err = db.SaveValidator(validator)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.SaveValidator %s error: %s", param.ConsensusAddress.Hex(), err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.SaveValidator %s error: %s", param.ConsensusAddress.Hex(), err)
 			}
-			err = v.db.AddStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
+			err = db.AddStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.AddStakeInfo error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.AddStakeInfo error: %s", err)
 			}
 
 		case node_manager_abi.MethodStake:
@@ -226,19 +317,19 @@ func (v *Listener) ScanAndExecBlock(height uint64) error {
 			method, _ := nmAbi.Methods[methodName.Name]
 			args, err := method.Inputs.Unpack(data[4:])
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
 			}
 			err = method.Inputs.Copy(param, args)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
 			}
-			err = v.db.AddStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
+			err = db.AddStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.AddStakeInfo error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.AddStakeInfo error: %s", err)
 			}
-			err = v.db.AddValidatorStake(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
+			err = db.AddValidatorStake(from.Hex(), param.ConsensusAddress.Hex(), transaction.Value())
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.AddValidatorStake error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.AddValidatorStake error: %s", err)
 			}
 
 		case node_manager_abi.MethodUnStake:
@@ -246,140 +337,260 @@ func (v *Listener) ScanAndExecBlock(height uint64) error {
 			method, _ := nmAbi.Methods[methodName.Name]
 			args, err := method.Inputs.Unpack(data[4:])
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Unpack error: %s", err)
 			}
 			err = method.Inputs.Copy(param, args)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock，method.Inputs.Copy error: %s", err)
 			}
-			err = v.db.SubStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), param.Amount)
+			err = db.SubStakeInfo(from.Hex(), param.ConsensusAddress.Hex(), param.Amount)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.SubStakeInfo error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.SubStakeInfo error: %s", err)
 			}
-			err = v.db.SubValidatorStake(param.ConsensusAddress.Hex(), param.Amount)
+			err = db.SubValidatorStake(param.ConsensusAddress.Hex(), param.Amount)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.SubValidatorStake error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.SubValidatorStake error: %s", err)
 			}
 
 		case node_manager_abi.MethodEndBlock:
-			err = v.CalcRewards(height)
+			err = v.CalcRewards(ctx, height)
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.CalcRewards error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, v.CalcRewards error: %s", err)
 			}
 
 		case node_manager_abi.MethodChangeEpoch:
-			num, err := v.db.LoadValidatorNum()
+			num, err := db.LoadValidatorNum()
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.LoadValidatorNum error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.LoadValidatorNum error: %s", err)
 			}
-			latestEpochInfo, err := v.db.LoadLatestEpochInfo()
+			latestEpochInfo, err := db.LoadLatestEpochInfo()
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.LoadLatestEpochInfo error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.LoadLatestEpochInfo error: %s", err)
 			}
 			ID := latestEpochInfo.ID + 1
 			var validators models.SQLStringArray
 			if num >= 4 {
-				newEpochInfo, err := v.GetEpochInfo(new(big.Int).SetUint64(ID))
+				newEpochInfo, err := v.GetEpochInfo(ctx, new(big.Int).SetUint64(ID))
 				if err != nil {
-					return fmt.Errorf("ScanAndExecBlock, v.GetEpochInfo error: %s", err)
+					return nil, fmt.Errorf("ScanAndExecBlock, v.GetEpochInfo error: %s", err)
 				}
 				for _, v := range newEpochInfo.Validators {
 					validators = append(validators, v.Hex())
 				}
 			}
-			err = v.db.SaveEpochInfo(&models.EpochInfo{
+			err = db.SaveEpochInfo(&models.EpochInfo{
 				ID:         ID,
 				Validators: validators,
 			})
 			if err != nil {
-				return fmt.Errorf("ScanAndExecBlock, v.db.SaveEpochInfo error: %s", err)
+				return nil, fmt.Errorf("ScanAndExecBlock, db.SaveEpochInfo error: %s", err)
 			}
 		default:
 		}
-		err = v.db.SaveDoneTx(&models.DoneTx{Hash: tx.Hash().Hex(), Height: height})
+		err = db.SaveDoneTx(&models.DoneTx{Hash: tx.Hash().Hex(), Height: height})
 		if err != nil {
-			return fmt.Errorf("ScanAndExecBlock, v.db.SaveDoneTx %s error: %s", tx.Hash().Hex(), err)
+			return nil, fmt.Errorf("ScanAndExecBlock, db.SaveDoneTx %s error: %s", tx.Hash().Hex(), err)
 		}
 	}
-	err = v.db.CleanDoneTx()
+	err = db.CleanDoneTx()
 	if err != nil {
-		return fmt.Errorf("CalcReward, v.db.CleanDoneTx error: %s", err)
+		return nil, fmt.Errorf("CalcReward, db.CleanDoneTx error: %s", err)
 	}
-	return nil
+	for method, n := range methods {
+		txsProcessed.WithLabelValues(method).Add(float64(n))
+	}
+	v.publishBlockProcessed(ctx, height, len(block.Transactions()), totalGas, endBlock)
+	return methods, nil
 }
 
-func (v *Listener) CalcRewards(height uint64) error {
-	accumulatedRewards, err := v.db.LoadAccumulatedRewards()
+func (v *Listener) publishBlockProcessed(ctx context.Context, height uint64, txCount int, totalGas *big.Int, endBlock bool) {
+	if v.bus == nil {
+		return
+	}
+	db := v.db.WithContext(ctx)
+	ev := &events.BlockProcessed{
+		Height:       height,
+		TxCount:      txCount,
+		TotalGas:     totalGas.String(),
+		TotalRewards: "0",
+	}
+	if endBlock {
+		rewardsCalc, err := db.LoadRewardsCalc(height)
+		if err != nil {
+			logger.With("height", height).Errorf("publishBlockProcessed, db.LoadRewardsCalc error: %s", err)
+		} else {
+			ev.TotalRewards = rewardsCalc.TotalRewards.String()
+			ev.ValidatorNum = rewardsCalc.ValidatorNum
+		}
+	}
+	accumulatedRewards, err := db.LoadAccumulatedRewards()
 	if err != nil {
-		return fmt.Errorf("CalcReward, v.db.LoadAccumulatedRewards error: %s", err)
+		logger.With("height", height).Errorf("publishBlockProcessed, db.LoadAccumulatedRewards error: %s", err)
+	} else {
+		ev.AccumulatedRewards = accumulatedRewards.String()
 	}
-	totalGas, err := v.db.LoadTotalGas(height)
+	v.bus.Publish(events.TOPIC_BLOCK_PROCESSED, ev)
+}
+
+func (v *Listener) CalcRewards(ctx context.Context, height uint64) (err error) {
+	ctx, span := tracing.Start(ctx, "Listener.CalcRewards", attribute.Int64("height", int64(height)))
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
+	db := v.db.WithContext(ctx)
+	accumulatedRewards, err := db.LoadAccumulatedRewards()
 	if err != nil {
-		return fmt.Errorf("CalcReward, v.db.LoadTotalGas error: %s", err)
+		return fmt.Errorf("CalcReward, db.LoadAccumulatedRewards error: %s", err)
 	}
-	communityRate, err := v.db.LoadCommunityRate()
+	totalGas, err := db.LoadTotalGas(height)
 	if err != nil {
-		return fmt.Errorf("CalcReward, v.db.LoadCommunityRate error: %s", err)
+		return fmt.Errorf("CalcReward, db.LoadTotalGas error: %s", err)
 	}
-	rewards := new(big.Int).Sub(params.ZNT1, new(big.Int).Div(new(big.Int).Mul(params.ZNT1, communityRate), node_manager.PercentDecimal))
+	communityRate, err := db.LoadCommunityRate()
+	if err != nil {
+		return fmt.Errorf("CalcReward, db.LoadCommunityRate error: %s", err)
+	}
+	rewards := calcBlockRewards(communityRate)
 	totalRewards := new(big.Int).Add(new(big.Int).Add(&totalGas.TotalGas.Int, rewards), accumulatedRewards)
 	// get validators in this block
-	epochInfo, err := v.db.LoadLatestEpochInfo()
+	epochInfo, err := db.LoadLatestEpochInfo()
 	if err != nil {
-		return fmt.Errorf("CalcReward, v.db.LoadLatestEpochInfo error: %s", err)
+		return fmt.Errorf("CalcReward, db.LoadLatestEpochInfo error: %s", err)
 	}
 	validatorList := epochInfo.Validators
+	rewardsCalc := &models.RewardsCalc{
+		Height:             height,
+		CommunityRate:      models.NewBigInt(communityRate),
+		BlockRewards:       models.NewBigInt(rewards),
+		TotalGas:           models.NewBigInt(&totalGas.TotalGas.Int),
+		AccumulatedRewards: models.NewBigInt(accumulatedRewards),
+		TotalRewards:       models.NewBigInt(totalRewards),
+		ValidatorNum:       uint64(len(validatorList)),
+		ValidatorRewards:   models.NewBigInt(new(big.Int)),
+	}
+	distributed, carryOver := new(big.Int), new(big.Int)
 	if len(validatorList) == 0 {
-		err = v.db.SaveAccumulatedRewards(totalRewards)
+		carryOver = totalRewards
+		err = db.SaveAccumulatedRewards(totalRewards)
 		if err != nil {
-			return fmt.Errorf("CalcReward, v.db.SaveAccumulatedRewards error: %s", err)
+			return fmt.Errorf("CalcReward, db.SaveAccumulatedRewards error: %s", err)
 		}
 	} else {
-		validatorRewards := new(big.Int).Div(totalRewards, new(big.Int).SetUint64(uint64(len(validatorList))))
+		validatorRewards := calcValidatorRewards(totalRewards, uint64(len(validatorList)))
+		rewardsCalc.ValidatorRewards = models.NewBigInt(validatorRewards)
+		err = saveDust(db, height, "", models.DustStageSplit, splitDust(totalRewards, validatorRewards, uint64(len(validatorList))))
 		if err != nil {
-			return fmt.Errorf("CalcReward, Div validatorRewards error: %v", err)
+			return fmt.Errorf("CalcReward, %s", err)
 		}
 		for _, consensusAddress := range validatorList {
 			// get validator
-			validator, err := v.db.LoadValidator(consensusAddress)
+			validator, err := db.LoadValidator(consensusAddress)
+			if err != nil {
+				return fmt.Errorf("CalcReward, db.LoadValidator error: %v", err)
+			}
+			commission, stakeRewards := calcCommission(validatorRewards, &validator.Commission.Int)
+			rewardsPerToken := calcRewardsPerToken(stakeRewards, &validator.TotalStake.Int)
+			err = db.SaveValidatorRewardsCalc(&models.ValidatorRewardsCalc{
+				Height:           height,
+				ConsensusAddress: consensusAddress,
+				StakeAddress:     validator.StakeAddress,
+				CommissionRate:   models.NewBigInt(&validator.Commission.Int),
+				TotalStake:       models.NewBigInt(&validator.TotalStake.Int),
+				Commission:       models.NewBigInt(commission),
+				StakeRewards:     models.NewBigInt(stakeRewards),
+				RewardsPerToken:  models.NewBigInt(rewardsPerToken),
+			})
 			if err != nil {
-				return fmt.Errorf("CalcReward, v.db.LoadValidator error: %v", err)
+				return fmt.Errorf("CalcReward, db.SaveValidatorRewardsCalc error: %v", err)
 			}
-			commission := new(big.Int).Div(new(big.Int).Mul(validatorRewards, &validator.Commission.Int), node_manager.PercentDecimal)
-			stakeRewards := new(big.Int).Sub(validatorRewards, commission)
-			rewardsPerToken := new(big.Int).Div(new(big.Int).Mul(stakeRewards, node_manager.TokenDecimal), &validator.TotalStake.Int)
-			allStakeAddress, err := v.db.LoadAllStakeAddress(consensusAddress)
+			allStakeAddress, err := db.LoadAllStakeAddress(consensusAddress)
 			if err != nil {
-				return fmt.Errorf("CalcReward, v.db.LoadAllStakeAddress error: %v", err)
+				return fmt.Errorf("CalcReward, db.LoadAllStakeAddress error: %v", err)
 			}
+			perStakerDust := new(big.Int)
 			for _, s := range allStakeAddress {
-				stakeInfo, err := v.db.LoadStakeInfo(s, consensusAddress)
+				stakeInfo, err := db.LoadStakeInfo(s, consensusAddress)
+				if err != nil {
+					return fmt.Errorf("CalcReward, db.LoadStakeInfo error: %v", err)
+				}
+				rewards := calcStakeRewards(&stakeInfo.Amount.Int, rewardsPerToken)
+				perStakerDust.Add(perStakerDust, stakeDust(&stakeInfo.Amount.Int, rewardsPerToken))
+				err = db.SaveRewardDetail(&models.RewardDetail{Address: s, Validator: consensusAddress, Height: height,
+					Kind: models.RewardKindStake, Stake: models.NewBigInt(&stakeInfo.Amount.Int), Amount: models.NewBigInt(rewards)})
 				if err != nil {
-					return fmt.Errorf("CalcReward, v.db.LoadStakeInfo error: %v", err)
+					return fmt.Errorf("CalcReward, db.SaveRewardDetail error: %v", err)
 				}
-				rewards := new(big.Int).Div(new(big.Int).Mul(&stakeInfo.Amount.Int, rewardsPerToken), node_manager.TokenDecimal)
-				oldRewards, err := v.db.LoadRewards(s, height)
+				distributed.Add(distributed, rewards)
+				oldRewards, err := db.LoadRewards(s, height)
 				if err != nil {
-					return fmt.Errorf("CalcReward, v.db.LoadRewards error: %v", err)
+					return fmt.Errorf("CalcReward, db.LoadRewards error: %v", err)
 				}
 				rewards = new(big.Int).Add(oldRewards, rewards)
 				if s == validator.StakeAddress {
+					err = db.SaveRewardDetail(&models.RewardDetail{Address: s, Validator: consensusAddress, Height: height,
+						Kind: models.RewardKindCommission, Stake: models.NewBigInt(&stakeInfo.Amount.Int), Amount: models.NewBigInt(commission)})
+					if err != nil {
+						return fmt.Errorf("CalcReward, db.SaveRewardDetail error: %v", err)
+					}
 					rewards = new(big.Int).Add(rewards, commission)
+					distributed.Add(distributed, commission)
 				}
-				err = v.db.SaveRewards(&models.Rewards{Address: s, Height: height, Amount: models.NewBigInt(rewards)})
+				err = db.SaveRewards(&models.Rewards{Address: s, Height: height, Amount: models.NewBigInt(rewards)})
 				if err != nil {
-					return fmt.Errorf("CalcReward, v.db.SaveRewards error: %v", err)
+					return fmt.Errorf("CalcReward, db.SaveRewards error: %v", err)
 				}
 			}
+			err = saveDust(db, height, consensusAddress, models.DustStagePerToken, perTokenDust(stakeRewards, &validator.TotalStake.Int))
+			if err != nil {
+				return fmt.Errorf("CalcReward, %s", err)
+			}
+			err = saveDust(db, height, consensusAddress, models.DustStagePerStaker, perStakerDust)
+			if err != nil {
+				return fmt.Errorf("CalcReward, %s", err)
+			}
 		}
-		err = v.db.SaveAccumulatedRewards(new(big.Int))
+		err = db.SaveAccumulatedRewards(new(big.Int))
 		if err != nil {
-			return fmt.Errorf("CalcReward, v.db.SaveAccumulatedRewards error: %s", err)
+			return fmt.Errorf("CalcReward, db.SaveAccumulatedRewards error: %s", err)
 		}
 	}
+	err = db.SaveRewardsCalc(rewardsCalc)
+	if err != nil {
+		return fmt.Errorf("CalcReward, db.SaveRewardsCalc error: %s", err)
+	}
+	observeRewards(distributed, carryOver)
 	return nil
 }
 
-func sleep() {
-	time.Sleep(time.Second)
+// calcBlockRewards returns the ZNT minted for validators in one block, after the community share.
+func calcBlockRewards(communityRate *big.Int) *big.Int {
+	return new(big.Int).Sub(params.ZNT1, new(big.Int).Div(new(big.Int).Mul(params.ZNT1, communityRate), node_manager.PercentDecimal))
+}
+
+// calcValidatorRewards splits the total rewards of a block evenly between validators.
+func calcValidatorRewards(totalRewards *big.Int, validatorNum uint64) *big.Int {
+	return new(big.Int).Div(totalRewards, new(big.Int).SetUint64(validatorNum))
+}
+
+// calcCommission returns the commission of a validator and the rewards left for its stakers.
+func calcCommission(validatorRewards, commissionRate *big.Int) (*big.Int, *big.Int) {
+	commission := new(big.Int).Div(new(big.Int).Mul(validatorRewards, commissionRate), node_manager.PercentDecimal)
+	return commission, new(big.Int).Sub(validatorRewards, commission)
+}
+
+func calcRewardsPerToken(stakeRewards, totalStake *big.Int) *big.Int {
+	return new(big.Int).Div(new(big.Int).Mul(stakeRewards, node_manager.TokenDecimal), totalStake)
+}
+
+func calcStakeRewards(stake, rewardsPerToken *big.Int) *big.Int {
+	return new(big.Int).Div(new(big.Int).Mul(stake, rewardsPerToken), node_manager.TokenDecimal)
+}
+
+// sleep waits a second or until ctx is done
+func sleep(ctx context.Context) {
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+	}
 }
@@ -7,10 +7,14 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/contracts/native/governance/node_manager"
 	"github.com/ethereum/go-ethereum/contracts/native/utils"
+	common2 "github.com/polynetwork/distribute-check/http/common"
+	"github.com/polynetwork/distribute-check/http/restful"
+	"github.com/polynetwork/distribute-check/store/models"
+	utils2 "github.com/polynetwork/distribute-check/utils"
 	"math/big"
 )
 
-func (v *Listener) GetEpochInfo(ID *big.Int) (*node_manager.EpochInfo, error) {
+func (v *Listener) GetEpochInfo(ctx context.Context, ID *big.Int) (*node_manager.EpochInfo, error) {
 	node_manager.InitABI()
 	input := &node_manager.GetEpochInfoParam{ID: ID}
 	payload, err := input.Encode()
@@ -22,7 +26,9 @@ func (v *Listener) GetEpochInfo(ID *big.Int) (*node_manager.EpochInfo, error) {
 		To:   &utils.NodeManagerContractAddress,
 		Data: payload,
 	}
-	r, err := v.client.CallContract(context.Background(), arg, nil)
+	rpcCtx, done := startRpc(ctx, "CallContract")
+	r, err := v.client.CallContract(rpcCtx, arg, nil)
+	done(err)
 	if err != nil {
 		return nil, fmt.Errorf("GetEpochInfo, v.client.CallContract error: %s", err)
 	}
@@ -34,26 +40,169 @@ func (v *Listener) GetEpochInfo(ID *big.Int) (*node_manager.EpochInfo, error) {
 	return epochInfo, nil
 }
 
-func (v *Listener) getRewards(addresses []string, endHeight uint64) ([]string, error) {
+func (v *Listener) getRewards(ctx context.Context, addresses []string, endHeight uint64, format *utils2.AmountFormat) ([]string, error) {
 	r := make([]string, 0, len(addresses))
 	for _, addr := range addresses {
-		ar, err := v.db.LoadAccumulateRewards(addr, endHeight)
+		ar, err := v.accumulatedRewards(ctx, addr, endHeight)
 		if err != nil {
-			return nil, fmt.Errorf("getRewards, v.db.LoadAccumulateRewards error: %s", err)
+			return nil, fmt.Errorf("getRewards, v.accumulatedRewards error: %s", err)
 		}
-		r = append(r, ar.String())
+		r = append(r, format.Format(ar))
 	}
 	return r, nil
 }
 
-func (v *Listener) getGasFee(addresses []string, endHeight uint64) ([]string, error) {
+func (v *Listener) getGasFee(ctx context.Context, addresses []string, endHeight uint64, format *utils2.AmountFormat) ([]string, error) {
 	r := make([]string, 0, len(addresses))
 	for _, addr := range addresses {
-		ar, err := v.db.LoadAccumulateGasFee(addr, endHeight)
+		ar, err := v.accumulatedGasFee(ctx, addr, endHeight)
 		if err != nil {
-			return nil, fmt.Errorf("getGasFee, v.db.LoadAccumulateGasFee error: %s", err)
+			return nil, fmt.Errorf("getGasFee, v.accumulatedGasFee error: %s", err)
 		}
-		r = append(r, ar.String())
+		r = append(r, format.Format(ar))
 	}
 	return r, nil
 }
+
+func (v *Listener) getRewardBreakdown(ctx context.Context, address string, startHeight, endHeight uint64, format *utils2.AmountFormat) ([]common2.RewardDetail, error) {
+	details, err := v.db.WithContext(ctx).LoadRewardDetails(address, startHeight, endHeight)
+	if err != nil {
+		return nil, fmt.Errorf("getRewardBreakdown, v.db.LoadRewardDetails error: %s", err)
+	}
+	r := make([]common2.RewardDetail, 0, len(details))
+	for _, d := range details {
+		r = append(r, common2.RewardDetail{
+			Validator: d.Validator,
+			Height:    d.Height,
+			Kind:      d.Kind,
+			Amount:    format.Format(&d.Amount.Int),
+		})
+	}
+	return r, nil
+}
+
+// writeAccumulated writes a row of the amount load accumulates for each
+// address up to endHeight.
+func writeAccumulated(ctx context.Context, w restful.RowWriter, addresses []string, endHeight uint64,
+	format *utils2.AmountFormat, load func(context.Context, string, uint64) (*big.Int, error)) error {
+	if err := w.Header("Address", "EndHeight", "Unit", "Amount"); err != nil {
+		return err
+	}
+	for _, addr := range addresses {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		amount, err := load(ctx, addr, endHeight)
+		if err != nil {
+			return fmt.Errorf("writeAccumulated, load %s error: %s", addr, err)
+		}
+		if err := w.Row(addr, endHeight, format.Unit, format.Format(amount)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRewardBreakdown streams the reward details of an address from the
+// database as rows.
+func (v *Listener) writeRewardBreakdown(ctx context.Context, w restful.RowWriter, address string, startHeight, endHeight uint64,
+	format *utils2.AmountFormat) error {
+	if err := w.Header("Address", "Validator", "Height", "Kind", "Unit", "Amount"); err != nil {
+		return err
+	}
+	err := v.db.WithContext(ctx).EachRewardDetail(address, startHeight, endHeight, func(d *models.RewardDetail) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return w.Row(address, d.Validator, d.Height, d.Kind, format.Unit, format.Format(&d.Amount.Int))
+	})
+	if err != nil {
+		return fmt.Errorf("writeRewardBreakdown, v.db.EachRewardDetail error: %s", err)
+	}
+	return nil
+}
+
+// explainRewards recomputes the rewards of an address at a height from the
+// values CalcRewards recorded, so every intermediate step can be audited.
+func (v *Listener) explainRewards(ctx context.Context, address string, height uint64) (*common2.ExplainRewardsResponse, error) {
+	db := v.db.WithContext(ctx)
+	rewardsCalc, err := db.LoadRewardsCalc(height)
+	if err != nil {
+		return nil, fmt.Errorf("explainRewards, db.LoadRewardsCalc error: %s", err)
+	}
+	details, err := db.LoadRewardDetails(address, height, height)
+	if err != nil {
+		return nil, fmt.Errorf("explainRewards, db.LoadRewardDetails error: %s", err)
+	}
+
+	blockRewards := calcBlockRewards(&rewardsCalc.CommunityRate.Int)
+	totalRewards := new(big.Int).Add(new(big.Int).Add(&rewardsCalc.TotalGas.Int, blockRewards), &rewardsCalc.AccumulatedRewards.Int)
+	validatorRewards := new(big.Int)
+	if rewardsCalc.ValidatorNum != 0 {
+		validatorRewards = calcValidatorRewards(totalRewards, rewardsCalc.ValidatorNum)
+	}
+	r := &common2.ExplainRewardsResponse{
+		Address:            address,
+		Height:             height,
+		BlockRewards:       blockRewards.String(),
+		CommunityRate:      rewardsCalc.CommunityRate.String(),
+		TotalGas:           rewardsCalc.TotalGas.String(),
+		AccumulatedRewards: rewardsCalc.AccumulatedRewards.String(),
+		TotalRewards:       totalRewards.String(),
+		ValidatorNum:       rewardsCalc.ValidatorNum,
+		ValidatorRewards:   validatorRewards.String(),
+		Validators:         make([]common2.ValidatorRewardsExplain, 0),
+	}
+
+	amount := new(big.Int)
+	storedAmount := new(big.Int)
+	explains := make(map[string]*common2.ValidatorRewardsExplain)
+	for _, d := range details {
+		storedAmount = new(big.Int).Add(storedAmount, &d.Amount.Int)
+		validatorRewardsCalc, err := db.LoadValidatorRewardsCalc(height, d.Validator)
+		if err != nil {
+			return nil, fmt.Errorf("explainRewards, db.LoadValidatorRewardsCalc error: %s", err)
+		}
+		stake := new(big.Int)
+		if d.Stake != nil {
+			stake = &d.Stake.Int
+		}
+		commission, stakeRewards := calcCommission(validatorRewards, &validatorRewardsCalc.CommissionRate.Int)
+		rewardsPerToken := calcRewardsPerToken(stakeRewards, &validatorRewardsCalc.TotalStake.Int)
+		e, ok := explains[d.Validator]
+		if !ok {
+			e = &common2.ValidatorRewardsExplain{
+				Validator:        d.Validator,
+				StakeAddress:     validatorRewardsCalc.StakeAddress,
+				CommissionRate:   validatorRewardsCalc.CommissionRate.String(),
+				TotalStake:       validatorRewardsCalc.TotalStake.String(),
+				Commission:       commission.String(),
+				StakeRewards:     stakeRewards.String(),
+				RewardsPerToken:  rewardsPerToken.String(),
+				Stake:            stake.String(),
+				StakeAmount:      "0",
+				CommissionAmount: "0",
+			}
+			explains[d.Validator] = e
+		}
+		switch d.Kind {
+		case models.RewardKindStake:
+			stakeAmount := calcStakeRewards(stake, rewardsPerToken)
+			e.StakeAmount = stakeAmount.String()
+			amount = new(big.Int).Add(amount, stakeAmount)
+		case models.RewardKindCommission:
+			e.CommissionAmount = commission.String()
+			amount = new(big.Int).Add(amount, commission)
+		}
+	}
+	for _, d := range details {
+		if e, ok := explains[d.Validator]; ok {
+			r.Validators = append(r.Validators, *e)
+			delete(explains, d.Validator)
+		}
+	}
+	r.Amount = amount.String()
+	r.StoredAmount = storedAmount.String()
+	r.Consistent = amount.Cmp(storedAmount) == 0
+	return r, nil
+}
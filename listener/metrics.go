@@ -0,0 +1,111 @@
+package listener
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/contracts/native/governance/node_manager"
+	"github.com/polynetwork/distribute-check/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	processedHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "distribute_check_processed_height",
+		Help: "Last block height handled.",
+	})
+	chainHead = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "distribute_check_chain_head",
+		Help: "Latest block height of zion.",
+	})
+	lagBlocks = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "distribute_check_lag_blocks",
+		Help: "Blocks between the chain head and the processed height.",
+	})
+	blocksProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "distribute_check_blocks_processed_total",
+		Help: "Blocks handled.",
+	})
+	txsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_txs_processed_total",
+		Help: "Node manager transactions handled by method.",
+	}, []string{"method"})
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "distribute_check_rpc_duration_seconds",
+		Help: "Latency of zion rpc calls by method.",
+	}, []string{"method"})
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_rpc_errors_total",
+		Help: "Failed zion rpc calls by method.",
+	}, []string{"method"})
+	rewardsDistributed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "distribute_check_rewards_distributed_wei_total",
+		Help: "Rewards credited to stakers and validators, in wei.",
+	})
+	rewardsCarryOver = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "distribute_check_accumulated_rewards_wei",
+		Help: "Rewards carried over to the next distribution, in wei.",
+	})
+	rewardDust = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_reward_dust_wei_total",
+		Help: "Rewards truncated by the integer divisions of the distribution, in wei, by stage.",
+	}, []string{"stage"})
+	invariantViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "distribute_check_invariant_violations_total",
+		Help: "Invariants found broken after a block, by invariant.",
+	}, []string{"invariant"})
+)
+
+func observeRpc(method string, start time.Time, err error) {
+	rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	entry := rpcLogger.With("method", method, "duration", time.Since(start))
+	if err != nil {
+		rpcErrors.WithLabelValues(method).Inc()
+		entry.Warnf("rpc call error: %s", err)
+		return
+	}
+	entry.Debug("rpc call")
+}
+
+// startRpc returns a func recording the metrics of an rpc call and ending its
+// span, calls are only traced as part of the span of ctx.
+func startRpc(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.StartChild(ctx, trace.SpanKindClient, "rpc."+method, semconv.RPCMethodKey.String(method))
+	return ctx, func(err error) {
+		observeRpc(method, start, err)
+		tracing.SetError(span, err)
+		span.End()
+	}
+}
+
+// observeHeights records the chain head and the last handled height
+func observeHeights(head, handled uint64) {
+	chainHead.Set(float64(head))
+	processedHeight.Set(float64(handled))
+	if head > handled {
+		lagBlocks.Set(float64(head - handled))
+	} else {
+		lagBlocks.Set(0)
+	}
+}
+
+func observeRewards(distributed, carryOver *big.Int) {
+	rewardsDistributed.Add(weiFloat(distributed))
+	rewardsCarryOver.Set(weiFloat(carryOver))
+}
+
+// observeDust records dust given in wei scaled by TokenDecimal
+func observeDust(stage string, amount *big.Int) {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(node_manager.TokenDecimal)).Float64()
+	rewardDust.WithLabelValues(stage).Add(f)
+}
+
+func weiFloat(amount *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(amount).Float64()
+	return f
+}
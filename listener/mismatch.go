@@ -0,0 +1,47 @@
+package listener
+
+import (
+	"context"
+
+	"github.com/polynetwork/distribute-check/events"
+	"github.com/polynetwork/distribute-check/store/models"
+)
+
+// Alerter is told of the events which may be alerts. Unlike subscribers of
+// the bus it sees every event, it is called synchronously while the block is
+// handled.
+type Alerter interface {
+	Mismatch(ctx context.Context, mismatch *events.Mismatch)
+	BlockFailed(ctx context.Context, failed *events.BlockFailed)
+	BlockProcessed(ctx context.Context, height uint64)
+}
+
+// SetAlerter makes the listener report its mismatches and failing blocks to
+// alerter.
+func (v *Listener) SetAlerter(alerter Alerter) {
+	v.alerter = alerter
+}
+
+// publishMismatch records a mismatch in the database, publishes it and
+// reports it to the alerter, halted records that the listener halts on it.
+func (v *Listener) publishMismatch(ctx context.Context, mismatch *events.Mismatch, halted bool) {
+	entry := logger.With("height", mismatch.Height, "check", mismatch.Check, "address", mismatch.Address,
+		"expected", mismatch.Expected, "actual", mismatch.Actual)
+	entry.Errorf("mismatch: %s", mismatch.Detail)
+	err := v.db.WithContext(ctx).SaveInvariantViolation(&models.InvariantViolation{
+		Height:    mismatch.Height,
+		Invariant: mismatch.Check,
+		Address:   mismatch.Address,
+		Expected:  mismatch.Expected,
+		Actual:    mismatch.Actual,
+		Detail:    mismatch.Detail,
+		Halted:    halted,
+	})
+	if err != nil {
+		entry.Errorf("publishMismatch, db.SaveInvariantViolation error: %s", err)
+	}
+	v.bus.Publish(events.TOPIC_MISMATCH, mismatch)
+	if v.alerter != nil {
+		v.alerter.Mismatch(ctx, mismatch)
+	}
+}
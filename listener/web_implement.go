@@ -1,74 +1,335 @@
 package listener
 
 import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/polynetwork/distribute-check/http/common"
 	"github.com/polynetwork/distribute-check/http/restful"
-	"github.com/polynetwork/distribute-check/log"
 	"github.com/polynetwork/distribute-check/utils"
 )
 
-func (v *Listener) GetRewards(param map[string]interface{}) map[string]interface{} {
-	req := &common.GetRewardsRequest{}
-	resp := &common.Response{}
-	err := utils.ParseParams(req, param)
+func (v *Listener) GetRewards(ctx context.Context, req *common.GetRewardsRequest) (*common.GetRewardsResponse, error) {
+	reqLog := logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETREWARDS, "addresses", req.Addresses, "end_height", req.EndHeight)
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
 	if err != nil {
-		resp.Error = restful.INVALID_PARAMS
-		resp.Desc = err.Error()
-		log.Errorf("GetRewards: decode params failed, err: %s", err)
-	} else {
-		rewards, err := v.getRewards(req.Addresses, req.EndHeight)
-		if err != nil {
-			resp.Error = restful.INTERNAL_ERROR
-			resp.Desc = err.Error()
-			log.Errorf("GetRewards error: %s", err)
-		} else {
-			resp.Error = restful.SUCCESS
-			resp.Result = &common.GetRewardsResponse{
-				Id:     req.Id,
-				Amount: rewards,
-			}
-			log.Infof("GetRewards success")
-		}
+		reqLog.Errorf("GetRewards: invalid amount format, err: %s", err)
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	rewards, err := v.getRewards(ctx, req.Addresses, req.EndHeight, format)
+	if err != nil {
+		reqLog.Errorf("GetRewards error: %s", err)
+		return nil, err
+	}
+	reqLog.Info("GetRewards success")
+	return &common.GetRewardsResponse{
+		Id:     req.Id,
+		Unit:   format.Unit,
+		Amount: rewards,
+	}, nil
+}
+
+func (v *Listener) GetGasFee(ctx context.Context, req *common.GetGasFeeRequest) (*common.GetGasFeeResponse, error) {
+	reqLog := logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETGASFEE, "addresses", req.Addresses, "end_height", req.EndHeight)
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		reqLog.Errorf("GetGasFee: invalid amount format, err: %s", err)
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	gasFee, err := v.getGasFee(ctx, req.Addresses, req.EndHeight, format)
+	if err != nil {
+		reqLog.Errorf("GetGasFee error: %s", err)
+		return nil, err
+	}
+	reqLog.Info("GetGasFee success")
+	return &common.GetGasFeeResponse{
+		Id:     req.Id,
+		Unit:   format.Unit,
+		Amount: gasFee,
+	}, nil
+}
+
+func (v *Listener) GetRewardBreakdown(ctx context.Context, req *common.GetRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error) {
+	reqLog := logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETREWARDBREAKDOWN, "address", req.Address, "start_height", req.StartHeight, "end_height", req.EndHeight)
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		reqLog.Errorf("GetRewardBreakdown: invalid amount format, err: %s", err)
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	details, err := v.getRewardBreakdown(ctx, req.Address, req.StartHeight, req.EndHeight, format)
+	if err != nil {
+		reqLog.Errorf("GetRewardBreakdown error: %s", err)
+		return nil, err
+	}
+	reqLog.Info("GetRewardBreakdown success")
+	return &common.GetRewardBreakdownResponse{
+		Id:      req.Id,
+		Address: req.Address,
+		Unit:    format.Unit,
+		Details: details,
+	}, nil
+}
+
+func (v *Listener) ExplainRewards(ctx context.Context, req *common.ExplainRewardsRequest) (*common.ExplainRewardsResponse, error) {
+	reqLog := logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_EXPLAINREWARDS, "address", req.Address, "height", req.Height)
+	explain, err := v.explainRewards(ctx, req.Address, req.Height)
+	if err != nil {
+		reqLog.Errorf("ExplainRewards error: %s", err)
+		return nil, err
 	}
+	reqLog.Info("ExplainRewards success")
+	explain.Id = req.Id
+	return explain, nil
+}
+
+func (v *Listener) AddressRewards(ctx context.Context, req *common.AddressRewardsRequest) (*common.AddressRewardsResponse, error) {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	rewards, err := v.getRewards(ctx, []string{req.Address}, req.EndHeight, format)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_ADDRESS_REWARDS, "address", req.Address).Errorf("AddressRewards error: %s", err)
+		return nil, err
+	}
+	return &common.AddressRewardsResponse{
+		Address:   req.Address,
+		EndHeight: req.EndHeight,
+		Unit:      format.Unit,
+		Amount:    rewards[0],
+	}, nil
+}
+
+func (v *Listener) AddressGasFee(ctx context.Context, req *common.AddressGasFeeRequest) (*common.AddressGasFeeResponse, error) {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	gasFee, err := v.getGasFee(ctx, []string{req.Address}, req.EndHeight, format)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_ADDRESS_GASFEE, "address", req.Address).Errorf("AddressGasFee error: %s", err)
+		return nil, err
+	}
+	return &common.AddressGasFeeResponse{
+		Address:   req.Address,
+		EndHeight: req.EndHeight,
+		Unit:      format.Unit,
+		Amount:    gasFee[0],
+	}, nil
+}
+
+func (v *Listener) AddressRewardBreakdown(ctx context.Context, req *common.AddressRewardBreakdownRequest) (*common.GetRewardBreakdownResponse, error) {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	details, err := v.getRewardBreakdown(ctx, req.Address, req.StartHeight, req.EndHeight, format)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_ADDRESS_BREAKDOWN, "address", req.Address).Errorf("AddressRewardBreakdown error: %s", err)
+		return nil, err
+	}
+	return &common.GetRewardBreakdownResponse{
+		Address: req.Address,
+		Unit:    format.Unit,
+		Details: details,
+	}, nil
+}
+
+func (v *Listener) AddressRewardsExplain(ctx context.Context, req *common.AddressRewardsExplainRequest) (*common.ExplainRewardsResponse, error) {
+	explain, err := v.explainRewards(ctx, req.Address, req.Height)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_ADDRESS_EXPLAIN, "address", req.Address, "height", req.Height).Errorf("AddressRewardsExplain error: %s", err)
+		return nil, err
+	}
+	return explain, nil
+}
+
+func (v *Listener) CacheStats(ctx context.Context, req *common.CacheStatsRequest) (*common.CacheStatsResponse, error) {
+	if v.cache == nil {
+		return &common.CacheStatsResponse{}, nil
+	}
+	stats := v.cache.lru.Stats()
+	return &common.CacheStatsResponse{
+		Enabled:       true,
+		Size:          stats.Size,
+		Capacity:      stats.Capacity,
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		Evictions:     stats.Evictions,
+		Invalidations: stats.Invalidations,
+	}, nil
+}
 
-	m, err := utils.RefactorResp(resp, resp.Error)
+func (v *Listener) Status(ctx context.Context, req *common.StatusRequest) (*common.StatusResponse, error) {
+	head, handled := atomic.LoadUint64(&v.progress.head), atomic.LoadUint64(&v.progress.handled)
+	resp := &common.StatusResponse{Head: head, Handled: handled}
+	if head > handled {
+		resp.Lag = head - handled
+	}
+	if at := atomic.LoadInt64(&v.progress.progressAt); at != 0 {
+		resp.LastProgress = time.Unix(0, at).UTC().Format(time.RFC3339)
+	}
+	epochInfo, err := v.db.WithContext(ctx).LoadLatestEpochInfo()
 	if err != nil {
-		log.Errorf("GetRewards: failed, err: %s", err)
-	} else {
-		log.Debug("GetRewards: resp success")
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_STATUS).Errorf("Status, LoadLatestEpochInfo error: %s", err)
+		return nil, err
 	}
-	return m
+	resp.EpochId = epochInfo.ID
+	return resp, nil
 }
 
-func (v *Listener) GetGasFee(param map[string]interface{}) map[string]interface{} {
-	req := &common.GetGasFeeRequest{}
-	resp := &common.Response{}
-	err := utils.ParseParams(req, param)
+func (v *Listener) Validators(ctx context.Context, req *common.ValidatorsRequest) (*common.ValidatorsResponse, error) {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	reqLog := logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_VALIDATORS)
+	db := v.db.WithContext(ctx)
+	epochInfo, err := db.LoadLatestEpochInfo()
 	if err != nil {
-		resp.Error = restful.INVALID_PARAMS
-		resp.Desc = err.Error()
-		log.Errorf("GetGasFee: decode params failed, err: %s", err)
-	} else {
-		gasFee, err := v.getGasFee(req.Addresses, req.EndHeight)
+		reqLog.Errorf("Validators, LoadLatestEpochInfo error: %s", err)
+		return nil, err
+	}
+	resp := &common.ValidatorsResponse{EpochId: epochInfo.ID, Unit: format.Unit, Validators: make([]common.ValidatorInfo, 0, len(epochInfo.Validators))}
+	for _, address := range epochInfo.Validators {
+		validator, err := db.LoadValidator(address)
+		if err != nil {
+			reqLog.With("address", address).Errorf("Validators, LoadValidator error: %s", err)
+			return nil, err
+		}
+		stakeAddresses, err := db.LoadAllStakeAddress(address)
 		if err != nil {
-			resp.Error = restful.INTERNAL_ERROR
-			resp.Desc = err.Error()
-			log.Errorf("GetGasFee error: %s", err)
-		} else {
-			resp.Error = restful.SUCCESS
-			resp.Result = &common.GetGasFeeResponse{
-				Id:     req.Id,
-				Amount: gasFee,
-			}
-			log.Infof("GetGasFee success")
+			reqLog.With("address", address).Errorf("Validators, LoadAllStakeAddress error: %s", err)
+			return nil, err
 		}
+		resp.Validators = append(resp.Validators, common.ValidatorInfo{
+			ConsensusAddress: validator.ConsensusAddress,
+			StakeAddress:     validator.StakeAddress,
+			Commission:       validator.Commission.String(),
+			TotalStake:       format.Format(&validator.TotalStake.Int),
+			SelfStake:        format.Format(&validator.SelfStake.Int),
+			Delegators:       len(stakeAddresses),
+		})
+	}
+	return resp, nil
+}
+
+func (v *Listener) Blocks(ctx context.Context, req *common.BlocksRequest) (*common.BlocksResponse, error) {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return nil, restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	limit, err := listLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	rewardsCalcs, err := v.db.WithContext(ctx).LoadLatestRewardsCalcs(limit)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_BLOCKS).Errorf("Blocks, LoadLatestRewardsCalcs error: %s", err)
+		return nil, err
+	}
+	resp := &common.BlocksResponse{Unit: format.Unit, Blocks: make([]common.BlockSummary, 0, len(rewardsCalcs))}
+	for _, calc := range rewardsCalcs {
+		resp.Blocks = append(resp.Blocks, common.BlockSummary{
+			Height:             calc.Height,
+			TotalGas:           format.Format(&calc.TotalGas.Int),
+			BlockRewards:       format.Format(&calc.BlockRewards.Int),
+			AccumulatedRewards: format.Format(&calc.AccumulatedRewards.Int),
+			TotalRewards:       format.Format(&calc.TotalRewards.Int),
+			ValidatorNum:       calc.ValidatorNum,
+		})
+	}
+	return resp, nil
+}
+
+func (v *Listener) BlockDust(ctx context.Context, req *common.BlockDustRequest) (*common.BlockDustResponse, error) {
+	dust, err := v.db.WithContext(ctx).LoadRewardDust(req.Height, req.Validator)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_BLOCK_DUST, "height", req.Height).Errorf("BlockDust, LoadRewardDust error: %s", err)
+		return nil, err
+	}
+	total, _ := sumDust(dust)
+	resp := &common.BlockDustResponse{Height: req.Height, Total: formatDust(total), Dust: make([]common.RewardDust, 0, len(dust))}
+	for _, d := range dust {
+		resp.Dust = append(resp.Dust, common.RewardDust{
+			Validator: d.Validator,
+			Stage:     d.Stage,
+			Amount:    formatDust(&d.Amount.Int),
+		})
 	}
+	return resp, nil
+}
 
-	m, err := utils.RefactorResp(resp, resp.Error)
+func (v *Listener) Mismatches(ctx context.Context, req *common.MismatchesRequest) (*common.MismatchesResponse, error) {
+	limit, err := listLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	violations, err := v.db.WithContext(ctx).LoadLatestInvariantViolations(limit)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_MISMATCHES).Errorf("Mismatches, LoadLatestInvariantViolations error: %s", err)
+		return nil, err
+	}
+	resp := &common.MismatchesResponse{Mismatches: make([]common.Mismatch, 0, len(violations))}
+	for _, violation := range violations {
+		resp.Mismatches = append(resp.Mismatches, common.Mismatch{
+			Height:   violation.Height,
+			Check:    violation.Invariant,
+			Address:  violation.Address,
+			Expected: violation.Expected,
+			Actual:   violation.Actual,
+			Detail:   violation.Detail,
+			Time:     violation.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return resp, nil
+}
+
+// listLimit defaults a list limit and checks its maximum
+func listLimit(limit uint64) (int, error) {
+	if limit == 0 {
+		return common.DEFAULT_LIST_LIMIT, nil
+	}
+	if limit > common.MAX_LIST_LIMIT {
+		return 0, restful.NewError(restful.INVALID_PARAMS, fmt.Sprintf("limit exceeds %d", common.MAX_LIST_LIMIT))
+	}
+	return int(limit), nil
+}
+
+func (v *Listener) StreamRewards(ctx context.Context, req *common.GetRewardsRequest, w restful.RowWriter) error {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	err = writeAccumulated(ctx, w, req.Addresses, req.EndHeight, format, v.accumulatedRewards)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETREWARDS, "addresses", req.Addresses).Errorf("StreamRewards error: %s", err)
+	}
+	return err
+}
+
+func (v *Listener) StreamGasFee(ctx context.Context, req *common.GetGasFeeRequest, w restful.RowWriter) error {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	err = writeAccumulated(ctx, w, req.Addresses, req.EndHeight, format, v.accumulatedGasFee)
+	if err != nil {
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETGASFEE, "addresses", req.Addresses).Errorf("StreamGasFee error: %s", err)
+	}
+	return err
+}
+
+func (v *Listener) StreamRewardBreakdown(ctx context.Context, req *common.GetRewardBreakdownRequest, w restful.RowWriter) error {
+	format, err := utils.NewAmountFormat(req.Unit, req.Decimals)
+	if err != nil {
+		return restful.NewError(restful.INVALID_PARAMS, err.Error())
+	}
+	err = v.writeRewardBreakdown(ctx, w, req.Address, req.StartHeight, req.EndHeight, format)
 	if err != nil {
-		log.Errorf("GetGasFee: failed, err: %s", err)
-	} else {
-		log.Debug("GetGasFee: resp success")
+		logger.With("request_id", restful.RequestId(ctx), "action", common.ACTION_GETREWARDBREAKDOWN, "address", req.Address).Errorf("StreamRewardBreakdown error: %s", err)
 	}
-	return m
+	return err
 }
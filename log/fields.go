@@ -0,0 +1,179 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+var jsonFormat int32
+
+// SetFormat switches every logger between colored text lines and one json
+// object per line.
+func SetFormat(format string) error {
+	switch format {
+	case FORMAT_TEXT:
+		atomic.StoreInt32(&jsonFormat, 0)
+	case FORMAT_JSON:
+		atomic.StoreInt32(&jsonFormat, 1)
+	default:
+		return errors.New("invalid log format " + format)
+	}
+	return nil
+}
+
+func isJSON() bool {
+	return atomic.LoadInt32(&jsonFormat) == 1
+}
+
+var jsonLevels = map[int]string{
+	TraceLog: "trace",
+	DebugLog: "debug",
+	InfoLog:  "info",
+	WarnLog:  "warn",
+	ErrorLog: "error",
+	FatalLog: "fatal",
+}
+
+// Entry logs with a component and key/value fields, e.g.
+//
+//	log.WithComponent("listener").With("height", h).Errorf("failed: %s", err)
+type Entry struct {
+	component string
+	fields    []interface{} // alternating keys and values
+}
+
+func WithComponent(component string) *Entry {
+	return &Entry{component: component}
+}
+
+// With returns an entry with fields, given as alternating keys and values.
+func With(kv ...interface{}) *Entry {
+	return new(Entry).With(kv...)
+}
+
+func (e *Entry) With(kv ...interface{}) *Entry {
+	fields := make([]interface{}, 0, len(e.fields)+len(kv))
+	fields = append(append(fields, e.fields...), kv...)
+	return &Entry{component: e.component, fields: fields}
+}
+
+func (e *Entry) output(level int, msg string) {
+	if level < componentLevel(e.component) {
+		return
+	}
+	l := Log()
+	if isJSON() {
+		_ = l.writeJSON(level, e.component, msg, e.fields)
+		return
+	}
+	var b strings.Builder
+	if e.component != "" {
+		b.WriteString("[" + e.component + "] ")
+	}
+	b.WriteString(msg)
+	for i := 0; i < len(e.fields); i += 2 {
+		b.WriteString(" " + fieldKey(e.fields[i]) + "=" + textValue(fieldValue(e.fields, i)))
+	}
+	_ = l.write(level, b.String())
+}
+
+func (e *Entry) Trace(a ...interface{}) { e.output(TraceLog, sprint(a...)) }
+func (e *Entry) Debug(a ...interface{}) { e.output(DebugLog, sprint(a...)) }
+func (e *Entry) Info(a ...interface{})  { e.output(InfoLog, sprint(a...)) }
+func (e *Entry) Warn(a ...interface{})  { e.output(WarnLog, sprint(a...)) }
+func (e *Entry) Error(a ...interface{}) { e.output(ErrorLog, sprint(a...)) }
+func (e *Entry) Fatal(a ...interface{}) { e.output(FatalLog, sprint(a...)) }
+
+func (e *Entry) Tracef(format string, a ...interface{}) {
+	e.output(TraceLog, fmt.Sprintf(format, a...))
+}
+func (e *Entry) Debugf(format string, a ...interface{}) {
+	e.output(DebugLog, fmt.Sprintf(format, a...))
+}
+func (e *Entry) Infof(format string, a ...interface{}) { e.output(InfoLog, fmt.Sprintf(format, a...)) }
+func (e *Entry) Warnf(format string, a ...interface{}) { e.output(WarnLog, fmt.Sprintf(format, a...)) }
+func (e *Entry) Errorf(format string, a ...interface{}) {
+	e.output(ErrorLog, fmt.Sprintf(format, a...))
+}
+func (e *Entry) Fatalf(format string, a ...interface{}) {
+	e.output(FatalLog, fmt.Sprintf(format, a...))
+}
+
+// sprint joins operands with spaces like the text logger does
+func sprint(a ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+}
+
+// writeJSON writes one json object with time, level, component, msg and the
+// fields in order.
+func (l *Logger) writeJSON(level int, component, msg string, fields []interface{}) error {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, jsonLevels[level])
+	if component != "" {
+		b.WriteString(`,"component":`)
+		writeJSONValue(&b, component)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+	for i := 0; i < len(fields); i += 2 {
+		b.WriteByte(',')
+		writeJSONValue(&b, fieldKey(fields[i]))
+		b.WriteByte(':')
+		writeJSONValue(&b, fieldValue(fields, i))
+	}
+	b.WriteString("}\n")
+	return l.jsonLogger.Output(0, b.String())
+}
+
+func fieldKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// fieldValue returns the value of the key at i, errors and Stringers such as
+// big.Int and hashes are logged as their text.
+func fieldValue(fields []interface{}, i int) interface{} {
+	if i+1 >= len(fields) {
+		return "(MISSING)"
+	}
+	switch v := fields[i+1].(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
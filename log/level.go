@@ -0,0 +1,150 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	COMPONENT_LISTENER = "listener"
+	COMPONENT_STORE    = "store"
+	COMPONENT_RESTFUL  = "restful"
+	COMPONENT_RPC      = "rpc"
+	COMPONENT_ALERT    = "alert"
+)
+
+// Components are the components levels can be set for
+var Components = []string{COMPONENT_LISTENER, COMPONENT_STORE, COMPONENT_RESTFUL, COMPONENT_RPC, COMPONENT_ALERT}
+
+var (
+	componentLock   sync.RWMutex
+	componentLevels = make(map[string]int)
+)
+
+// ParseLevel parses a level name such as debug or info
+func ParseLevel(name string) (int, error) {
+	for level, n := range jsonLevels {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %s", name)
+}
+
+func LevelString(level int) string {
+	if name, ok := jsonLevels[level]; ok {
+		return name
+	}
+	return strings.ToLower(NAME_PREFIX) + fmt.Sprint(level)
+}
+
+// Level returns the level of the global logger
+func Level() int {
+	return Log().getLevel()
+}
+
+// CheckComponent checks a component is one of Components
+func CheckComponent(component string) error {
+	if component == "" {
+		return errors.New("empty log component")
+	}
+	for _, c := range Components {
+		if c == component {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log component %s, expected one of %s", component, strings.Join(Components, ", "))
+}
+
+// SetComponentLevel sets the level of the entries of a component, overriding
+// the level of the global logger.
+func SetComponentLevel(component string, level int) error {
+	if err := CheckComponent(component); err != nil {
+		return err
+	}
+	if level > MaxLevelLog || level < 0 {
+		return errors.New("invalid Debug Level")
+	}
+	componentLock.Lock()
+	defer componentLock.Unlock()
+	componentLevels[component] = level
+	return nil
+}
+
+// ResetComponentLevel makes a component log at the global level again
+func ResetComponentLevel(component string) {
+	componentLock.Lock()
+	defer componentLock.Unlock()
+	delete(componentLevels, component)
+}
+
+// ComponentLevels returns the names of the levels set per component
+func ComponentLevels() map[string]string {
+	componentLock.RLock()
+	defer componentLock.RUnlock()
+	levels := make(map[string]string, len(componentLevels))
+	for component, level := range componentLevels {
+		levels[component] = LevelString(level)
+	}
+	return levels
+}
+
+func componentLevel(component string) int {
+	if component != "" {
+		componentLock.RLock()
+		level, ok := componentLevels[component]
+		componentLock.RUnlock()
+		if ok {
+			return level
+		}
+	}
+	return Log().getLevel()
+}
+
+// SetLevels parses a comma separated list of levels, an entry without a
+// component sets the global level, e.g. "info,listener=debug,store=warn".
+func SetLevels(spec string) error {
+	global := -1
+	levels := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		component, name := "", item
+		if i := strings.Index(item, "="); i >= 0 {
+			component, name = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+			if err := CheckComponent(component); err != nil {
+				return fmt.Errorf("invalid log level %s: %s", item, err)
+			}
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		if component == "" {
+			global = level
+		} else {
+			levels[component] = level
+		}
+	}
+	if global >= 0 {
+		if err := Log().SetDebugLevel(global); err != nil {
+			return err
+		}
+	}
+	components := make([]string, 0, len(levels))
+	for component := range levels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		if err := SetComponentLevel(component, levels[component]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
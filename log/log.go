@@ -115,16 +115,18 @@ func LevelName(level int) string {
 }
 
 type Logger struct {
-	level   int
-	logger  *log.Logger
-	logFile *os.File
+	level      int32
+	logger     *log.Logger
+	jsonLogger *log.Logger
+	logFile    *RotateFile
 }
 
-func New(out io.Writer, prefix string, flag, level int, file *os.File) *Logger {
+func New(out io.Writer, prefix string, flag, level int, file *RotateFile) *Logger {
 	return &Logger{
-		level:   level,
-		logger:  log.New(out, prefix, flag),
-		logFile: file,
+		level:      int32(level),
+		logger:     log.New(out, prefix, flag),
+		jsonLogger: log.New(out, "", 0),
+		logFile:    file,
 	}
 }
 
@@ -133,12 +135,19 @@ func (l *Logger) SetDebugLevel(level int) error {
 		return errors.New("invalid Debug Level")
 	}
 
-	l.level = level
+	atomic.StoreInt32(&l.level, int32(level))
 	return nil
 }
 
+func (l *Logger) getLevel() int {
+	return int(atomic.LoadInt32(&l.level))
+}
+
 func (l *Logger) Output(level int, a ...interface{}) error {
-	if level >= l.level {
+	if level >= l.getLevel() {
+		if isJSON() {
+			return l.writeJSON(level, "", sprint(a...), nil)
+		}
 		gid := GetGID()
 		gidStr := strconv.FormatUint(gid, 10)
 
@@ -150,8 +159,19 @@ func (l *Logger) Output(level int, a ...interface{}) error {
 	return nil
 }
 
+// write outputs a message at level regardless of the level of the logger
+func (l *Logger) write(level int, msg string) error {
+	if isJSON() {
+		return l.writeJSON(level, "", msg, nil)
+	}
+	return l.logger.Output(CALL_DEPTH, fmt.Sprintf("%s GID %d, %s\n", LevelName(level), GetGID(), msg))
+}
+
 func (l *Logger) Outputf(level int, format string, v ...interface{}) error {
-	if level >= l.level {
+	if level >= l.getLevel() {
+		if isJSON() {
+			return l.writeJSON(level, "", fmt.Sprintf(format, v...), nil)
+		}
 		gid := GetGID()
 		v = append([]interface{}{LevelName(level), "GID",
 			gid}, v...)
@@ -210,7 +230,7 @@ func (l *Logger) Fatalf(format string, a ...interface{}) {
 }
 
 func Trace(a ...interface{}) {
-	if TraceLog < Log().level {
+	if TraceLog < Log().getLevel() {
 		return
 	}
 
@@ -230,7 +250,7 @@ func Trace(a ...interface{}) {
 }
 
 func Tracef(format string, a ...interface{}) {
-	if TraceLog < Log().level {
+	if TraceLog < Log().getLevel() {
 		return
 	}
 
@@ -250,7 +270,7 @@ func Tracef(format string, a ...interface{}) {
 }
 
 func Debug(a ...interface{}) {
-	if DebugLog < Log().level {
+	if DebugLog < Log().getLevel() {
 		return
 	}
 
@@ -266,7 +286,7 @@ func Debug(a ...interface{}) {
 }
 
 func Debugf(format string, a ...interface{}) {
-	if DebugLog < Log().level {
+	if DebugLog < Log().getLevel() {
 		return
 	}
 
@@ -347,7 +367,7 @@ func Init(a ...interface{}) {
 
 func createLog(logLevel int, a ...interface{}) *Logger {
 	var writers []io.Writer
-	var logFile *os.File
+	var logFile *RotateFile
 	var err error
 	if len(a) == 0 {
 		writers = append(writers, ioutil.Discard)
@@ -359,7 +379,7 @@ func createLog(logLevel int, a ...interface{}) *Logger {
 					fmt.Println("warn: only support one log file")
 					continue
 				}
-				logFile, err = FileOpen(o.(string))
+				logFile, err = OpenRotateFile(o.(string), getRotateConfig())
 				if err != nil {
 					fmt.Println("error: open log file failed")
 					os.Exit(1)
@@ -390,11 +410,10 @@ func InitLog(logLevel int, a ...interface{}) {
 }
 
 func GetLogFileSize() (int64, error) {
-	f, e := Log().logFile.Stat()
-	if e != nil {
-		return 0, e
+	if Log().logFile == nil {
+		return 0, errors.New("no log file")
 	}
-	return f.Size(), nil
+	return Log().logFile.Size(), nil
 }
 
 func GetMaxLogChangeInterval(maxLogSize int64) int64 {
@@ -405,33 +424,6 @@ func GetMaxLogChangeInterval(maxLogSize int64) int64 {
 	}
 }
 
-func checkIfNeedNewFile() bool {
-	logFileSize, err := GetLogFileSize()
-	maxLogFileSize := GetMaxLogChangeInterval(0)
-	if err != nil {
-		return false
-	}
-	if logFileSize > maxLogFileSize {
-		return true
-	} else {
-		return false
-	}
-}
-
 func ClosePrintLog() error {
-	var err error
-	if Log().logFile != nil {
-		err = Log().logFile.Close()
-	}
-	return err
-}
-
-func CheckRotateLogFile() {
-	isNeedNewFile := checkIfNeedNewFile()
-	if isNeedNewFile {
-		old := swapGlobalLogger(createLog(Log().level, PATH, Stdout))
-		if old.logFile != nil {
-			_ = old.logFile.Close()
-		}
-	}
+	return Log().logFile.Close()
 }
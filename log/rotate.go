@@ -0,0 +1,253 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOG_FILE_SUFFIX = "_LOG.log"
+	GZIP_SUFFIX     = ".gz"
+)
+
+// RotateConfig controls when the log file is rotated and how long rotated
+// files are kept, zero values disable the respective limit.
+type RotateConfig struct {
+	MaxSize  int64         // bytes written before a new file is started
+	Daily    bool          // start a new file when the utc day changes
+	Compress bool          // gzip rotated files
+	MaxFiles int           // rotated files kept
+	MaxAge   time.Duration // rotated files older than this are removed
+}
+
+var (
+	rotateLock   sync.Mutex
+	rotateConfig = RotateConfig{MaxSize: GetMaxLogChangeInterval(0)}
+)
+
+// SetRotateConfig sets the rotation of log files opened afterwards, e.g. by
+// InitLog.
+func SetRotateConfig(config RotateConfig) {
+	rotateLock.Lock()
+	defer rotateLock.Unlock()
+	rotateConfig = config
+}
+
+func getRotateConfig() RotateConfig {
+	rotateLock.Lock()
+	defer rotateLock.Unlock()
+	return rotateConfig
+}
+
+// RotateFile writes to a file in dir and switches to a new one when the
+// file gets too large or the day changes. Rotated files are compressed
+// and pruned in the background.
+type RotateFile struct {
+	dir    string
+	config RotateConfig
+	lock   sync.Mutex
+	file   *os.File
+	size   int64
+	day    string
+	wg     sync.WaitGroup // running compressions
+	clean  sync.Mutex     // serializes compressing and pruning
+}
+
+func OpenRotateFile(dir string, config RotateConfig) (*RotateFile, error) {
+	if fi, err := os.Stat(dir); err == nil {
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("open %s: not a directory", dir)
+		}
+	} else if os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0766); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+	f := &RotateFile{dir: dir, config: config}
+	if err := f.open(time.Now()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open starts a new file named after now. Names have millisecond resolution,
+// a sequence number is appended when the name, or its compressed file, is
+// already taken, so a rotation never reopens a rotated file.
+func (f *RotateFile) open(now time.Time) error {
+	base := filepath.Join(f.dir, now.Format("2006-01-02_15.04.05.000"))
+	for seq := 0; ; seq++ {
+		name := base + LOG_FILE_SUFFIX
+		if seq > 0 {
+			name = fmt.Sprintf("%s.%d%s", base, seq, LOG_FILE_SUFFIX)
+		}
+		if _, err := os.Stat(name + GZIP_SUFFIX); err == nil {
+			continue
+		}
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		f.file = file
+		f.size = 0
+		f.day = now.UTC().Format("2006-01-02")
+		return nil
+	}
+}
+
+func (f *RotateFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	now := time.Now()
+	if f.size > 0 && f.needRotate(now, int64(len(p))) {
+		// keep writing to the current file if a new one can not be opened
+		if err := f.rotate(now); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotateFile) needRotate(now time.Time, n int64) bool {
+	if f.config.MaxSize > 0 && f.size+n > f.config.MaxSize {
+		return true
+	}
+	return f.config.Daily && now.UTC().Format("2006-01-02") != f.day
+}
+
+// Rotate starts a new file regardless of the limits
+func (f *RotateFile) Rotate() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate(time.Now())
+}
+
+func (f *RotateFile) rotate(now time.Time) error {
+	old := f.file
+	if err := f.open(now); err != nil {
+		return fmt.Errorf("rotate log file error: %s", err)
+	}
+	if err := old.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "close log file %s error: %s\n", old.Name(), err)
+	}
+	current := f.file.Name()
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.clean.Lock()
+		defer f.clean.Unlock()
+		if f.config.Compress {
+			if err := compressFile(old.Name()); err != nil {
+				fmt.Fprintf(os.Stderr, "compress log file %s error: %s\n", old.Name(), err)
+			}
+		}
+		f.prune(current)
+	}()
+	return nil
+}
+
+// prune removes the rotated files beyond the max count or age
+func (f *RotateFile) prune(current string) {
+	if f.config.MaxFiles <= 0 && f.config.MaxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read log dir %s error: %s\n", f.dir, err)
+		return
+	}
+	type rotated struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]rotated, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(f.dir, name)
+		if path == current || entry.IsDir() ||
+			!(strings.HasSuffix(name, LOG_FILE_SUFFIX) || strings.HasSuffix(name, LOG_FILE_SUFFIX+GZIP_SUFFIX)) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotated{path: path, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for i, file := range files {
+		if (f.config.MaxFiles > 0 && i >= f.config.MaxFiles) ||
+			(f.config.MaxAge > 0 && time.Since(file.modTime) > f.config.MaxAge) {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "remove log file %s error: %s\n", file.path, err)
+			}
+		}
+	}
+}
+
+// Size returns the bytes written to the current file
+func (f *RotateFile) Size() int64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.size
+}
+
+// Close closes the current file and waits for running compressions
+func (f *RotateFile) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.lock.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.lock.Unlock()
+	f.wg.Wait()
+	return err
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+GZIP_SUFFIX, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + GZIP_SUFFIX)
+		return err
+	}
+	return os.Remove(name)
+}
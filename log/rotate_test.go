@@ -0,0 +1,165 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logFiles lists the names of the log files in dir, compressed or not
+func logFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasSuffix(name, LOG_FILE_SUFFIX) || strings.HasSuffix(name, LOG_FILE_SUFFIX+GZIP_SUFFIX) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readLog(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, GZIP_SUFFIX) {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotateSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	f := &RotateFile{dir: dir, config: RotateConfig{Compress: true}}
+	now := time.Date(2023, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	if err := f.open(now); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a", "b", "c"} {
+		if _, err := f.file.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.rotate(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.file.WriteString("d")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2023-01-02_03.04.05.006.1_LOG.log.gz",
+		"2023-01-02_03.04.05.006.2_LOG.log.gz",
+		"2023-01-02_03.04.05.006.3_LOG.log",
+		"2023-01-02_03.04.05.006_LOG.log.gz",
+	}
+	names := logFiles(t, dir)
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("files %v, want %v", names, want)
+	}
+	for i, content := range []string{"b", "c", "d", "a"} {
+		if got := readLog(t, filepath.Join(dir, names[i])); got != content {
+			t.Errorf("%s holds %q, want %q", names[i], got, content)
+		}
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenRotateFile(dir, RotateConfig{MaxSize: 4, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("abc"))
+	// exceeds MaxSize, so it starts a new file
+	f.Write([]byte("de"))
+	current := f.file.Name()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names := logFiles(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("files %v, want the compressed and the current file", names)
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		want := "abc"
+		if path == current {
+			want = "de"
+		} else if !strings.HasSuffix(name, GZIP_SUFFIX) {
+			t.Errorf("rotated file %s not compressed", name)
+		}
+		if got := readLog(t, path); got != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+}
+
+// rotatedFiles creates the log files of names in dir, the first one the
+// newest, each an hour older than the previous one
+func rotatedFiles(t *testing.T, dir string, names ...string) {
+	now := time.Now()
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0666); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RotateConfig
+		kept   []string
+	}{
+		{"no limits", RotateConfig{}, []string{"a_LOG.log", "b_LOG.log.gz", "c_LOG.log", "d_LOG.log.gz"}},
+		{"max files", RotateConfig{MaxFiles: 2}, []string{"a_LOG.log", "b_LOG.log.gz"}},
+		{"max age", RotateConfig{MaxAge: 90 * time.Minute}, []string{"a_LOG.log", "b_LOG.log.gz"}},
+		{"both", RotateConfig{MaxFiles: 1, MaxAge: 150 * time.Minute}, []string{"a_LOG.log"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// the current file is the oldest, it is never pruned
+			rotatedFiles(t, dir, "a_LOG.log", "b_LOG.log.gz", "c_LOG.log", "d_LOG.log.gz", "current_LOG.log")
+			if err := os.WriteFile(filepath.Join(dir, "other.txt"), nil, 0666); err != nil {
+				t.Fatal(err)
+			}
+			f := &RotateFile{dir: dir, config: test.config}
+			f.prune(filepath.Join(dir, "current_LOG.log"))
+			want := append([]string{"current_LOG.log"}, test.kept...)
+			sort.Strings(want)
+			if names := logFiles(t, dir); strings.Join(names, ",") != strings.Join(want, ",") {
+				t.Errorf("kept %v, want %v", names, want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "other.txt")); err != nil {
+				t.Errorf("file other than a log pruned: %s", err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"github.com/polynetwork/distribute-check/store/migrations"
 	"github.com/polynetwork/distribute-check/store/models"
@@ -12,7 +13,8 @@ import (
 
 // Client holds a connection to the database.
 type Client struct {
-	db *gorm.DB
+	db  *gorm.DB
+	ctx context.Context // parent of the spans of the queries, may be nil
 }
 
 // ConnectToDB attempts to connect to the database URI provided,
@@ -31,7 +33,32 @@ func ConnectToDb(uri string) (*Client, error) {
 	return store, nil
 }
 
+// WithContext returns a client whose queries are traced as children of the
+// span of ctx and canceled with it.
+func (client Client) WithContext(ctx context.Context) *Client {
+	return &Client{db: client.db.WithContext(ctx), ctx: ctx}
+}
+
+// Close closes the connection pool of the database.
+func (client Client) Close() error {
+	sqlDB, err := client.db.DB()
+	if err != nil {
+		return fmt.Errorf("Close, client.db.DB error: %s", err)
+	}
+	return sqlDB.Close()
+}
+
+// Ping checks the database is reachable.
+func (client Client) Ping(ctx context.Context) error {
+	sqlDB, err := client.db.DB()
+	if err != nil {
+		return fmt.Errorf("Ping, client.db.DB error: %s", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 func (client Client) LoadTrackHeight() (uint64, error) {
+	defer client.observe("LoadTrackHeight")()
 	trackHeight := &models.TrackHeight{
 		Height: 1,
 	}
@@ -40,6 +67,7 @@ func (client Client) LoadTrackHeight() (uint64, error) {
 }
 
 func (client Client) SaveTrackHeight(height uint64) error {
+	defer client.observe("SaveTrackHeight")()
 	trackHeight := &models.TrackHeight{
 		Name:   "height",
 		Height: height,
@@ -48,18 +76,21 @@ func (client Client) SaveTrackHeight(height uint64) error {
 }
 
 func (client Client) LoadValidator(consensusAddress string) (*models.Validator, error) {
+	defer client.observe("LoadValidator")()
 	validator := new(models.Validator)
 	err := client.db.Where(&models.Validator{ConsensusAddress: consensusAddress}).First(validator).Error
 	return validator, err
 }
 
 func (client Client) LoadValidatorNum() (uint64, error) {
+	defer client.observe("LoadValidatorNum")()
 	var num uint64
 	err := client.db.Raw("SELECT COUNT(*) FROM validators").Scan(&num).Error
 	return num, err
 }
 
 func (client Client) SaveValidator(validator *models.Validator) error {
+	defer client.observe("SaveValidator")()
 	return client.db.Save(validator).Error
 }
 
@@ -93,16 +124,35 @@ func (client Client) SubValidatorStake(consensusAddress string, amount *big.Int)
 }
 
 func (client Client) LoadLatestEpochInfo() (*models.EpochInfo, error) {
+	defer client.observe("LoadLatestEpochInfo")()
 	epochInfo := new(models.EpochInfo)
 	err := client.db.Where(&models.EpochInfo{}).Last(epochInfo).Error
 	return epochInfo, err
 }
 
 func (client Client) SaveEpochInfo(epochInfo *models.EpochInfo) error {
+	defer client.observe("SaveEpochInfo")()
 	return client.db.Save(epochInfo).Error
 }
 
+// LoadValidators loads every validator ever created
+func (client Client) LoadValidators() ([]models.Validator, error) {
+	defer client.observe("LoadValidators")()
+	validators := make([]models.Validator, 0)
+	err := client.db.Find(&validators).Error
+	return validators, err
+}
+
+// LoadAllStakeInfo loads the stakes of every staker of every validator
+func (client Client) LoadAllStakeInfo() ([]models.StakeInfo, error) {
+	defer client.observe("LoadAllStakeInfo")()
+	stakeInfos := make([]models.StakeInfo, 0)
+	err := client.db.Find(&stakeInfos).Error
+	return stakeInfos, err
+}
+
 func (client Client) LoadStakeInfo(stakeAddress, consensusAddr string) (*models.StakeInfo, error) {
+	defer client.observe("LoadStakeInfo")()
 	stakeInfo := &models.StakeInfo{
 		Amount: models.NewBigInt(new(big.Int)),
 	}
@@ -111,12 +161,14 @@ func (client Client) LoadStakeInfo(stakeAddress, consensusAddr string) (*models.
 }
 
 func (client Client) LoadAllStakeAddress(consensusAddr string) ([]string, error) {
+	defer client.observe("LoadAllStakeAddress")()
 	r := make([]string, 0)
 	err := client.db.Model(&models.StakeInfo{}).Select("stake_address").Where("consensus_address = ?", consensusAddr).Find(&r).Error
 	return r, err
 }
 
 func (client Client) SaveStakeInfo(stakeInfo *models.StakeInfo) error {
+	defer client.observe("SaveStakeInfo")()
 	return client.db.Save(stakeInfo).Error
 }
 
@@ -147,30 +199,36 @@ func (client Client) SubStakeInfo(stakeAddress, consensusAddress string, amount
 }
 
 func (client Client) LoadDoneTx(hash string) ([]models.DoneTx, error) {
+	defer client.observe("LoadDoneTx")()
 	doneTx := make([]models.DoneTx, 0)
 	err := client.db.Where(&models.DoneTx{Hash: hash}).Find(&doneTx).Error
 	return doneTx, err
 }
 
 func (client Client) SaveDoneTx(doneTx *models.DoneTx) error {
+	defer client.observe("SaveDoneTx")()
 	return client.db.Save(doneTx).Error
 }
 
 func (client Client) CleanDoneTx() error {
+	defer client.observe("CleanDoneTx")()
 	return client.db.Where("1 = 1").Delete(&models.DoneTx{}).Error
 }
 
 func (client Client) LoadTotalGas(height uint64) (*models.TotalGas, error) {
+	defer client.observe("LoadTotalGas")()
 	totalGas := new(models.TotalGas)
 	err := client.db.Where(&models.TotalGas{Height: height}).First(totalGas).Error
 	return totalGas, err
 }
 
 func (client Client) SaveTotalGas(totalGas *models.TotalGas) error {
+	defer client.observe("SaveTotalGas")()
 	return client.db.Save(totalGas).Error
 }
 
 func (client Client) LoadAccumulateGasFee(address string, height uint64) (*big.Int, error) {
+	defer client.observe("LoadAccumulateGasFee")()
 	r := make([]models.GasFee, 0)
 	err := client.db.Where("address = ? AND height <= ?", address, height).Find(&r).Error
 	agf := new(big.Int)
@@ -181,10 +239,12 @@ func (client Client) LoadAccumulateGasFee(address string, height uint64) (*big.I
 }
 
 func (client Client) SaveGasFee(gasFee *models.GasFee) error {
+	defer client.observe("SaveGasFee")()
 	return client.db.Save(gasFee).Error
 }
 
 func (client Client) LoadAccumulateRewards(address string, height uint64) (*big.Int, error) {
+	defer client.observe("LoadAccumulateRewards")()
 	r := make([]models.Rewards, 0)
 	err := client.db.Where("address = ? AND height <= ?", address, height).Find(&r).Error
 	ar := new(big.Int)
@@ -195,10 +255,12 @@ func (client Client) LoadAccumulateRewards(address string, height uint64) (*big.
 }
 
 func (client Client) SaveRewards(rewards *models.Rewards) error {
+	defer client.observe("SaveRewards")()
 	return client.db.Save(rewards).Error
 }
 
 func (client Client) LoadRewards(address string, height uint64) (*big.Int, error) {
+	defer client.observe("LoadRewards")()
 	rewards := &models.Rewards{
 		Address: address,
 		Height:  height,
@@ -208,7 +270,106 @@ func (client Client) LoadRewards(address string, height uint64) (*big.Int, error
 	return &rewards.Amount.Int, err
 }
 
+func (client Client) SaveRewardDetail(rewardDetail *models.RewardDetail) error {
+	defer client.observe("SaveRewardDetail")()
+	return client.db.Save(rewardDetail).Error
+}
+
+func (client Client) LoadRewardDetails(address string, startHeight, endHeight uint64) ([]models.RewardDetail, error) {
+	defer client.observe("LoadRewardDetails")()
+	r := make([]models.RewardDetail, 0)
+	err := client.db.Where("address = ? AND height >= ? AND height <= ?", address, startHeight, endHeight).
+		Order("height, validator, kind").Find(&r).Error
+	return r, err
+}
+
+// EachRewardDetail calls fn with the reward details of an address in the
+// order of LoadRewardDetails, reading one row at a time.
+func (client Client) EachRewardDetail(address string, startHeight, endHeight uint64, fn func(*models.RewardDetail) error) error {
+	defer client.observe("EachRewardDetail")()
+	rows, err := client.db.Model(&models.RewardDetail{}).
+		Where("address = ? AND height >= ? AND height <= ?", address, startHeight, endHeight).
+		Order("height, validator, kind").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		detail := new(models.RewardDetail)
+		if err := client.db.ScanRows(rows, detail); err != nil {
+			return err
+		}
+		if err := fn(detail); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LoadRewardDetailsAtHeight loads the rewards credited at a height
+func (client Client) LoadRewardDetailsAtHeight(height uint64) ([]models.RewardDetail, error) {
+	defer client.observe("LoadRewardDetailsAtHeight")()
+	details := make([]models.RewardDetail, 0)
+	err := client.db.Where("height = ?", height).Find(&details).Error
+	return details, err
+}
+
+func (client Client) LoadRewardsCalc(height uint64) (*models.RewardsCalc, error) {
+	defer client.observe("LoadRewardsCalc")()
+	rewardsCalc := new(models.RewardsCalc)
+	err := client.db.Where(&models.RewardsCalc{Height: height}).First(rewardsCalc).Error
+	return rewardsCalc, err
+}
+
+// FindRewardsCalc loads the values CalcRewards used at a height, nil if
+// rewards were not calculated at it.
+func (client Client) FindRewardsCalc(height uint64) (*models.RewardsCalc, error) {
+	defer client.observe("FindRewardsCalc")()
+	rewardsCalcs := make([]models.RewardsCalc, 0, 1)
+	err := client.db.Where("height = ?", height).Limit(1).Find(&rewardsCalcs).Error
+	if err != nil || len(rewardsCalcs) == 0 {
+		return nil, err
+	}
+	return &rewardsCalcs[0], nil
+}
+
+// LoadLatestRewardsCalcs loads the limit highest heights rewards were
+// calculated at, the highest first.
+func (client Client) LoadLatestRewardsCalcs(limit int) ([]models.RewardsCalc, error) {
+	defer client.observe("LoadLatestRewardsCalcs")()
+	rewardsCalcs := make([]models.RewardsCalc, 0)
+	err := client.db.Order("height desc").Limit(limit).Find(&rewardsCalcs).Error
+	return rewardsCalcs, err
+}
+
+func (client Client) SaveRewardsCalc(rewardsCalc *models.RewardsCalc) error {
+	defer client.observe("SaveRewardsCalc")()
+	return client.db.Save(rewardsCalc).Error
+}
+
+func (client Client) LoadValidatorRewardsCalc(height uint64, consensusAddress string) (*models.ValidatorRewardsCalc, error) {
+	defer client.observe("LoadValidatorRewardsCalc")()
+	validatorRewardsCalc := new(models.ValidatorRewardsCalc)
+	err := client.db.Where(&models.ValidatorRewardsCalc{Height: height, ConsensusAddress: consensusAddress}).First(validatorRewardsCalc).Error
+	return validatorRewardsCalc, err
+}
+
+// LoadValidatorRewardsCalcsAtHeight loads how the rewards of every validator
+// were split at a height.
+func (client Client) LoadValidatorRewardsCalcsAtHeight(height uint64) ([]models.ValidatorRewardsCalc, error) {
+	defer client.observe("LoadValidatorRewardsCalcsAtHeight")()
+	validatorRewardsCalcs := make([]models.ValidatorRewardsCalc, 0)
+	err := client.db.Where("height = ?", height).Find(&validatorRewardsCalcs).Error
+	return validatorRewardsCalcs, err
+}
+
+func (client Client) SaveValidatorRewardsCalc(validatorRewardsCalc *models.ValidatorRewardsCalc) error {
+	defer client.observe("SaveValidatorRewardsCalc")()
+	return client.db.Save(validatorRewardsCalc).Error
+}
+
 func (client Client) LoadAccumulatedRewards() (*big.Int, error) {
+	defer client.observe("LoadAccumulatedRewards")()
 	accumulatedRewards := models.AccumulatedRewards{
 		Amount: models.NewBigInt(new(big.Int)),
 	}
@@ -217,6 +378,7 @@ func (client Client) LoadAccumulatedRewards() (*big.Int, error) {
 }
 
 func (client Client) SaveAccumulatedRewards(amount *big.Int) error {
+	defer client.observe("SaveAccumulatedRewards")()
 	accumulatedRewards := &models.AccumulatedRewards{
 		Name:   "accumulatedRewards",
 		Amount: models.NewBigInt(amount),
@@ -225,15 +387,76 @@ func (client Client) SaveAccumulatedRewards(amount *big.Int) error {
 }
 
 func (client Client) LoadCommunityRate() (*big.Int, error) {
+	defer client.observe("LoadCommunityRate")()
 	communityRate := new(models.CommunityRate)
 	err := client.db.Where(&models.CommunityRate{Name: "communityRate"}).First(communityRate).Error
 	return &communityRate.Amount.Int, err
 }
 
 func (client Client) SaveCommunityRate(amount *big.Int) error {
+	defer client.observe("SaveCommunityRate")()
 	communityRate := &models.CommunityRate{
 		Name:   "communityRate",
 		Amount: models.NewBigInt(amount),
 	}
 	return client.db.Save(communityRate).Error
 }
+
+// LoadApiKey returns nil if no key has the id.
+func (client Client) SaveRewardDust(rewardDust *models.RewardDust) error {
+	defer client.observe("SaveRewardDust")()
+	return client.db.Save(rewardDust).Error
+}
+
+// LoadRewardDust loads the dust truncated at a height, of one validator if
+// given, the split of the block first.
+func (client Client) LoadRewardDust(height uint64, validator string) ([]models.RewardDust, error) {
+	defer client.observe("LoadRewardDust")()
+	query := client.db.Where("height = ?", height)
+	if validator != "" {
+		query = query.Where("validator = ?", validator)
+	}
+	dust := make([]models.RewardDust, 0)
+	err := query.Order("validator, stage").Find(&dust).Error
+	return dust, err
+}
+
+func (client Client) SaveInvariantViolation(violation *models.InvariantViolation) error {
+	defer client.observe("SaveInvariantViolation")()
+	return client.db.Create(violation).Error
+}
+
+// LoadLatestInvariantViolations loads up to limit violations, the latest first
+func (client Client) LoadLatestInvariantViolations(limit int) ([]models.InvariantViolation, error) {
+	defer client.observe("LoadLatestInvariantViolations")()
+	violations := make([]models.InvariantViolation, 0)
+	err := client.db.Order("id desc").Limit(limit).Find(&violations).Error
+	return violations, err
+}
+
+// LoadHaltingViolation loads the first violation the listener halted on
+// which was not acknowledged, nil if none.
+func (client Client) LoadHaltingViolation() (*models.InvariantViolation, error) {
+	defer client.observe("LoadHaltingViolation")()
+	r := make([]models.InvariantViolation, 0)
+	err := client.db.Where("halted = ? AND acknowledged = ?", true, false).Order("id").Limit(1).Find(&r).Error
+	if err != nil || len(r) == 0 {
+		return nil, err
+	}
+	return &r[0], nil
+}
+
+func (client Client) LoadApiKey(id string) (*models.ApiKey, error) {
+	defer client.observe("LoadApiKey")()
+	r := make([]models.ApiKey, 0)
+	err := client.db.Where(&models.ApiKey{Id: id}).Limit(1).Find(&r).Error
+	if err != nil || len(r) == 0 {
+		return nil, err
+	}
+	return &r[0], nil
+}
+
+func (client Client) SaveApiKey(apiKey *models.ApiKey) error {
+	defer client.observe("SaveApiKey")()
+	return client.db.Save(apiKey).Error
+}
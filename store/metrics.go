@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/polynetwork/distribute-check/log"
+	"github.com/polynetwork/distribute-check/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = log.WithComponent(log.COMPONENT_STORE)
+
+var dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "distribute_check_db_duration_seconds",
+	Help: "Latency of database queries by store.Client method.",
+}, []string{"method"})
+
+// observe returns a func recording, logging and tracing the latency of method
+// when deferred. Only methods running queries themselves are observed, those
+// composing other methods are not so no query is counted twice. Queries
+// are only traced as part of the span of the context of the client.
+func (client Client) observe(method string) func() {
+	start := time.Now()
+	ctx := client.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.StartChild(ctx, trace.SpanKindClient, "store."+method, semconv.DBSystemPostgreSQL)
+	return func() {
+		dbDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		logger.With("method", method, "duration", time.Since(start)).Debug("database query")
+		span.End()
+	}
+}
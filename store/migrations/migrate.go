@@ -49,6 +49,21 @@ func Migrate(db *gorm.DB) error {
 		return fmt.Errorf("failed to auto migrate Rewards: %s", err)
 	}
 
+	err = db.AutoMigrate(&models.RewardDetail{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate RewardDetail: %s", err)
+	}
+
+	err = db.AutoMigrate(&models.RewardsCalc{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate RewardsCalc: %s", err)
+	}
+
+	err = db.AutoMigrate(&models.ValidatorRewardsCalc{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate ValidatorRewardsCalc: %s", err)
+	}
+
 	err = db.AutoMigrate(&models.AccumulatedRewards{})
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate AccumulatedRewards: %s", err)
@@ -59,5 +74,20 @@ func Migrate(db *gorm.DB) error {
 		return fmt.Errorf("failed to auto migrate CommunityRate: %s", err)
 	}
 
+	err = db.AutoMigrate(&models.RewardDust{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate RewardDust: %s", err)
+	}
+
+	err = db.AutoMigrate(&models.InvariantViolation{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate InvariantViolation: %s", err)
+	}
+
+	err = db.AutoMigrate(&models.ApiKey{})
+	if err != nil {
+		return fmt.Errorf("failed to auto migrate ApiKey: %s", err)
+	}
+
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"time"
 )
 
 type TrackHeight struct {
@@ -56,6 +57,46 @@ type Rewards struct {
 	Amount  *BigInt `gorm:"type:varchar(64)"`
 }
 
+// RewardDetail is one component of the rewards an address earned at a height,
+// split by the validator pool it came from and its kind.
+type RewardDetail struct {
+	Address   string  `gorm:"primary_key"`
+	Validator string  `gorm:"primary_key"`
+	Height    uint64  `gorm:"primary_key"`
+	Kind      string  `gorm:"primary_key"`
+	Stake     *BigInt `gorm:"type:varchar(64)"`
+	Amount    *BigInt `gorm:"type:varchar(64)"`
+}
+
+const (
+	RewardKindStake      = "stake"
+	RewardKindCommission = "commission"
+)
+
+// RewardsCalc records the block level values CalcRewards used at a height.
+type RewardsCalc struct {
+	Height             uint64  `gorm:"primary_key"`
+	CommunityRate      *BigInt `gorm:"type:varchar(64)"`
+	BlockRewards       *BigInt `gorm:"type:varchar(64)"`
+	TotalGas           *BigInt `gorm:"type:varchar(64)"`
+	AccumulatedRewards *BigInt `gorm:"type:varchar(64)"`
+	TotalRewards       *BigInt `gorm:"type:varchar(64)"`
+	ValidatorNum       uint64
+	ValidatorRewards   *BigInt `gorm:"type:varchar(64)"`
+}
+
+// ValidatorRewardsCalc records how CalcRewards split the rewards of one validator at a height.
+type ValidatorRewardsCalc struct {
+	Height           uint64 `gorm:"primary_key"`
+	ConsensusAddress string `gorm:"primary_key"`
+	StakeAddress     string
+	CommissionRate   *BigInt `gorm:"type:varchar(64)"`
+	TotalStake       *BigInt `gorm:"type:varchar(64)"`
+	Commission       *BigInt `gorm:"type:varchar(64)"`
+	StakeRewards     *BigInt `gorm:"type:varchar(64)"`
+	RewardsPerToken  *BigInt `gorm:"type:varchar(64)"`
+}
+
 type AccumulatedRewards struct {
 	Name   string  `gorm:"primary_key"`
 	Amount *BigInt `gorm:"type:varchar(64)"`
@@ -66,6 +107,45 @@ type CommunityRate struct {
 	Amount *BigInt `gorm:"type:varchar(64)"`
 }
 
+// RewardDust records what a stage of CalcRewards truncated at a height,
+// Amount is in wei scaled by TokenDecimal as the stages truncate fractions of
+// a wei. The split stage is of the whole block and has no Validator.
+type RewardDust struct {
+	Height    uint64  `gorm:"primary_key"`
+	Validator string  `gorm:"primary_key"`
+	Stage     string  `gorm:"primary_key"`
+	Amount    *BigInt `gorm:"type:varchar(128)"`
+}
+
+const (
+	DustStageSplit     = "split"     // the block rewards split evenly between validators
+	DustStagePerToken  = "pertoken"  // the rewards per token of a validator
+	DustStagePerStaker = "perstaker" // the rewards of each staker of a validator
+)
+
+// InvariantViolation records an invariant found broken after a block,
+// Address is the validator it concerns if any. A listener halted on a
+// violation resumes after its block once Acknowledged is set.
+type InvariantViolation struct {
+	ID           uint64 `gorm:"primary_key"`
+	Height       uint64 `gorm:"index"`
+	Invariant    string
+	Address      string
+	Expected     string `gorm:"type:varchar(128)"`
+	Actual       string `gorm:"type:varchar(128)"`
+	Detail       string `gorm:"type:varchar(1024)"`
+	Halted       bool
+	Acknowledged bool
+	CreatedAt    time.Time
+}
+
+// ApiKey is a credential of the rest api with its scopes.
+type ApiKey struct {
+	Id     string `gorm:"primary_key"`
+	Secret string
+	Scopes SQLStringArray `gorm:"type:varchar(256)"`
+}
+
 // SQLStringArray is a string array stored in the database as comma separated values.
 type SQLStringArray []string
 
@@ -121,6 +201,9 @@ func (bigInt *BigInt) Value() (driver.Value, error) {
 }
 
 func (bigInt *BigInt) Scan(v interface{}) error {
+	if v == nil {
+		return nil
+	}
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("type error, not string")
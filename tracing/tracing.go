@@ -0,0 +1,166 @@
+// Package tracing sets up OpenTelemetry tracing of the service, spans are
+// exported over OTLP/HTTP or as json to a file or stdout. Libraries
+// instrumented with OpenTelemetry join the traces through the global
+// provider and propagator.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_FILE   = "file"
+	EXPORTER_STDOUT = "stdout"
+
+	OTLP_TRACES_PATH = "/v1/traces"
+
+	// INSTRUMENTATION names the tracer of the service
+	INSTRUMENTATION = "github.com/polynetwork/distribute-check"
+)
+
+// Config of the tracing, Endpoint is used by the otlp exporter and File by
+// the file exporter.
+type Config struct {
+	ServiceName string
+	Exporter    string
+	Endpoint    string  // url of the otlp/http collector
+	File        string  // json file the spans are appended to
+	SampleRatio float64 // share of traces recorded, all if 0
+}
+
+var (
+	provider *sdktrace.TracerProvider
+	closer   io.Closer
+)
+
+// Init installs the global tracer provider and the W3C trace context
+// propagator, spans are exported in batches until Shutdown.
+func Init(ctx context.Context, config Config) error {
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return err
+	}
+	ratio := config.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return nil
+}
+
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case EXPORTER_OTLP:
+		u, err := url.Parse(config.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("newExporter, invalid otlp endpoint %s", config.Endpoint)
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		if !strings.HasSuffix(path, OTLP_TRACES_PATH) {
+			path += OTLP_TRACES_PATH
+		}
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithURLPath(path)}
+		if u.Scheme == "http" {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("newExporter, otlptracehttp.New error: %s", err)
+		}
+		return exporter, nil
+	case EXPORTER_FILE:
+		file, err := os.OpenFile(config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("newExporter, os.OpenFile error: %s", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("newExporter, stdouttrace.New error: %s", err)
+		}
+		closer = file
+		return exporter, nil
+	case EXPORTER_STDOUT:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("newExporter, stdouttrace.New error: %s", err)
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("newExporter, invalid exporter %s", config.Exporter)
+}
+
+// Shutdown exports the spans left within ctx and stops the provider.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	if closer != nil {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Start starts an internal span as a child of the span of ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return StartKind(ctx, trace.SpanKindInternal, name, attrs...)
+}
+
+func StartKind(ctx context.Context, kind trace.SpanKind, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(INSTRUMENTATION).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// StartChild is StartKind for work only traced as part of the span of ctx,
+// e.g. queries, the span does nothing if ctx has none.
+func StartChild(ctx context.Context, kind trace.SpanKind, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return StartKind(ctx, kind, name, attrs...)
+}
+
+// SetError marks the span failed if err is not nil
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Extract returns ctx carrying the remote span of the headers of a request
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject sets the headers carrying the span of ctx to another process
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -8,6 +8,13 @@ import (
 	"strings"
 )
 
+const (
+	UNIT_WEI = "wei"
+	UNIT_ZNT = "znt"
+
+	ZNT_DECIMALS = 18
+)
+
 func ParseParams(req interface{}, params map[string]interface{}) error {
 	jsonData, err := json.Marshal(params)
 	if err != nil {
@@ -77,6 +84,8 @@ func removeZeroAtTail(str string) string {
 	return str
 }
 
+// ToIntByPrecise converts a decimal string to an integer of precise decimals.
+// Digits beyond precise are dropped and malformed input yields 0.
 func ToIntByPrecise(str string, precise uint64) *big.Int {
 	result := new(big.Int)
 	splits := strings.Split(str, ".")
@@ -112,3 +121,56 @@ func ToIntByPrecise(str string, precise uint64) *big.Int {
 
 	return result
 }
+
+// AmountFormat is the unit amounts of a response are given in. Amounts in
+// znt have all their decimals unless Decimals rounds them.
+type AmountFormat struct {
+	Unit     string
+	Decimals *uint64
+}
+
+func NewAmountFormat(unit string, decimals *uint64) (*AmountFormat, error) {
+	switch strings.ToLower(unit) {
+	case "", UNIT_WEI:
+		if decimals != nil {
+			return nil, fmt.Errorf("NewAmountFormat: decimals require unit %s", UNIT_ZNT)
+		}
+		return &AmountFormat{Unit: UNIT_WEI}, nil
+	case UNIT_ZNT:
+		if decimals != nil && *decimals > ZNT_DECIMALS {
+			return nil, fmt.Errorf("NewAmountFormat: decimals %d exceed %d", *decimals, ZNT_DECIMALS)
+		}
+		return &AmountFormat{Unit: UNIT_ZNT, Decimals: decimals}, nil
+	}
+	return nil, fmt.Errorf("NewAmountFormat: unknown unit %q, expected %s or %s", unit, UNIT_WEI, UNIT_ZNT)
+}
+
+// Format returns the wei amount in the unit of the format, rounding half away
+// from zero to the decimals.
+func (f *AmountFormat) Format(amount *big.Int) string {
+	if f.Unit != UNIT_ZNT {
+		return amount.String()
+	}
+	if f.Decimals == nil {
+		return ToStringByPrecise(amount, ZNT_DECIMALS)
+	}
+	decimals := *f.Decimals
+	scale := new(big.Int).Exp(big.NewInt(10), new(big.Int).SetUint64(ZNT_DECIMALS-decimals), nil)
+	q, r := new(big.Int).QuoRem(new(big.Int).Abs(amount), scale, new(big.Int))
+	if r.Lsh(r, 1).Cmp(scale) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	digits := q.String()
+	if pad := int(decimals) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	result := digits
+	if decimals > 0 {
+		point := len(digits) - int(decimals)
+		result = digits[:point] + "." + digits[point:]
+	}
+	if amount.Sign() < 0 && q.Sign() != 0 {
+		result = "-" + result
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+)
+
+func decimals(d uint64) *uint64 {
+	return &d
+}
+
+func TestNewAmountFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		unit     string
+		decimals *uint64
+		want     string
+		err      bool
+	}{
+		{"default", "", nil, UNIT_WEI, false},
+		{"wei", "wei", nil, UNIT_WEI, false},
+		{"znt", "ZNT", nil, UNIT_ZNT, false},
+		{"znt decimals", "znt", decimals(2), UNIT_ZNT, false},
+		{"znt all decimals", "znt", decimals(ZNT_DECIMALS), UNIT_ZNT, false},
+		{"too many decimals", "znt", decimals(ZNT_DECIMALS + 1), "", true},
+		{"wei decimals", "wei", decimals(2), "", true},
+		{"default decimals", "", decimals(0), "", true},
+		{"unknown unit", "eth", nil, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, err := NewAmountFormat(test.unit, test.decimals)
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", format)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.Unit != test.want {
+				t.Errorf("unit %s, want %s", format.Unit, test.want)
+			}
+		})
+	}
+}
+
+func TestAmountFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		unit     string
+		decimals *uint64
+		amount   string
+		want     string
+	}{
+		{"wei", UNIT_WEI, nil, "1234567890123456789", "1234567890123456789"},
+		{"wei negative", UNIT_WEI, nil, "-5", "-5"},
+		{"znt", UNIT_ZNT, nil, "1234500000000000000", "1.2345"},
+		{"znt whole", UNIT_ZNT, nil, "2000000000000000000", "2"},
+		{"znt below one", UNIT_ZNT, nil, "1", "0.000000000000000001"},
+		{"znt negative", UNIT_ZNT, nil, "-1500000000000000000", "-1.5"},
+		{"round down", UNIT_ZNT, decimals(2), "1234999999999999999", "1.23"},
+		{"round half up", UNIT_ZNT, decimals(2), "1235000000000000000", "1.24"},
+		{"trailing zeros", UNIT_ZNT, decimals(3), "1000000000000000000", "1.000"},
+		{"negative round half away", UNIT_ZNT, decimals(2), "-1235000000000000000", "-1.24"},
+		{"negative round down", UNIT_ZNT, decimals(2), "-1234999999999999999", "-1.23"},
+		{"negative rounded to zero", UNIT_ZNT, decimals(2), "-4000000000000000", "0.00"},
+		{"padded below one", UNIT_ZNT, decimals(4), "1200000000000000", "0.0012"},
+		{"zero decimals", UNIT_ZNT, decimals(0), "2500000000000000000", "3"},
+		{"zero decimals below half", UNIT_ZNT, decimals(0), "400000000000000000", "0"},
+		{"zero decimals half", UNIT_ZNT, decimals(0), "500000000000000000", "1"},
+		{"zero decimals negative half", UNIT_ZNT, decimals(0), "-500000000000000000", "-1"},
+		{"all decimals", UNIT_ZNT, decimals(ZNT_DECIMALS), "1", "0.000000000000000001"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount, ok := new(big.Int).SetString(test.amount, 10)
+			if !ok {
+				t.Fatalf("invalid amount %s", test.amount)
+			}
+			format := &AmountFormat{Unit: test.unit, Decimals: test.decimals}
+			if got := format.Format(amount); got != test.want {
+				t.Errorf("Format(%s) = %s, want %s", test.amount, got, test.want)
+			}
+		})
+	}
+}